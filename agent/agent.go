@@ -1,17 +1,73 @@
 package agent
 
-import "google.golang.org/genai"
+import (
+	"sync"
+	"sync/atomic"
+
+	"agent/llm"
+)
 
 type Agent struct {
-	ID                  string
-	History             []*genai.Content
-	StoryID             string          // Story ID for database queries
-	CharacterID         string          // Character ID this agent represents
-	CharacterName       string          // Character name for dialogue
-	Personality         string          // Character personality for response modification
-	HoldsEvidenceIDs    []string        // Evidence IDs character has
-	KnowsLocationIDs    []string        // Location IDs character knows
-	RevealedEvidenceIDs map[string]bool // Track revealed evidence
-	RevealedLocationIDs map[string]bool // Track revealed locations
-	LoadedFromDB        bool            // Track if agent was loaded from DB (may need format reminders)
+	ID                   string
+	History              []llm.Message
+	StoryID              string          // Story ID for database queries
+	StoryCollection      string          // Collection the story lives in, "stories" if empty
+	SessionID            string          // Player session this agent belongs to, if any
+	PlayerID             string          // Player who owns this agent, if spawned with authentication
+	CharacterID          string          // Character ID this agent represents
+	CharacterName        string          // Character name for dialogue
+	Personality          string          // Character personality for response modification
+	HoldsEvidenceIDs     []string        // Evidence IDs character has
+	KnowsLocationIDs     []string        // Location IDs character knows
+	RevealedEvidenceIDs  map[string]bool // Track revealed evidence
+	RevealedLocationIDs  map[string]bool // Track revealed locations
+	PresentedEvidenceIDs map[string]bool // Evidence already presented to the character, counted once for standing
+	Reputation           int             // How much the character trusts the investigator
+	Intimidation         int             // How much pressure the character feels under
+	LoadedFromDB         bool            // Track if agent was loaded from DB (may need format reminders)
+	NextIndex            int             // Index the next persisted conversation message will use
+	Summary              string          // Rolling summary of compacted turns, attached to the system prompt
+	SummaryThroughIndex  int             // Last conversation index covered by Summary
+
+	// mu serializes turns so History, the reveal maps and NextIndex are
+	// only ever mutated by one message at a time
+	mu sync.Mutex
+	// pins counts turns and background work using the agent; the registry
+	// never evicts a pinned agent, so no second copy can be loaded meanwhile
+	pins atomic.Int32
+}
+
+// Lock acquires exclusive access to the agent's mutable state
+func (a *Agent) Lock() {
+	a.mu.Lock()
+}
+
+// Unlock releases the lock acquired by Lock
+func (a *Agent) Unlock() {
+	a.mu.Unlock()
+}
+
+// Pin keeps the agent in the registry until the matching Unpin. It must only
+// be called while the agent is already pinned, e.g. to hand it to background
+// work started during a turn; use AcquireAgent to pin it in the first place.
+func (a *Agent) Pin() {
+	a.pins.Add(1)
+}
+
+// Unpin releases a pin taken by Pin or AcquireAgent
+func (a *Agent) Unpin() {
+	a.pins.Add(-1)
+}
+
+// pinned reports whether a turn or background work is using the agent
+func (a *Agent) pinned() bool {
+	return a.pins.Load() > 0
+}
+
+// ReserveIndexes returns the first of n consecutive conversation indexes and
+// advances NextIndex past them. The caller must hold the agent lock.
+func (a *Agent) ReserveIndexes(n int) int {
+	start := a.NextIndex
+	a.NextIndex += n
+	return start
 }
@@ -0,0 +1,298 @@
+package agent
+
+import (
+	"container/list"
+	"log"
+	"sync"
+	"time"
+)
+
+// RegistryConfig controls the size and eviction policy of a Registry
+type RegistryConfig struct {
+	MaxSize int                // Maximum number of agents kept in memory (0 = unbounded)
+	IdleTTL time.Duration      // Agents idle for longer than this are evicted (0 = never)
+	OnEvict func(agent *Agent) // Called outside the lock for every evicted agent
+}
+
+// RegistryStats is a snapshot of registry counters
+type RegistryStats struct {
+	Size      int    `json:"size"`
+	MaxSize   int    `json:"max_size"`
+	Hits      uint64 `json:"hits"`
+	Misses    uint64 `json:"misses"`
+	Evictions uint64 `json:"evictions"`
+}
+
+type registryEntry struct {
+	agent      *Agent
+	lastAccess time.Time
+}
+
+// Registry is an in-memory LRU cache of live agents with idle-time eviction.
+// Pinned agents are never evicted, so the registry may briefly hold more than
+// MaxSize agents while they are all in use.
+type Registry struct {
+	mu       sync.Mutex
+	entries  map[string]*list.Element
+	order    *list.List // front = most recently used
+	flushing map[string]chan struct{}
+	cfg      RegistryConfig
+	now      func() time.Time
+
+	hits      uint64
+	misses    uint64
+	evictions uint64
+}
+
+// NewRegistry creates an empty registry with the given policy
+func NewRegistry(cfg RegistryConfig) *Registry {
+	return &Registry{
+		entries:  make(map[string]*list.Element),
+		order:    list.New(),
+		flushing: make(map[string]chan struct{}),
+		cfg:      cfg,
+		now:      time.Now,
+	}
+}
+
+// Get returns the agent with the given ID and marks it as recently used
+func (r *Registry) Get(id string) (*Agent, bool) {
+	return r.get(id, false)
+}
+
+// Acquire is Get that also pins the agent, so it can't be evicted before the
+// caller's Unpin
+func (r *Registry) Acquire(id string) (*Agent, bool) {
+	return r.get(id, true)
+}
+
+func (r *Registry) get(id string, pin bool) (*Agent, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	elem, ok := r.entries[id]
+	if !ok {
+		r.misses++
+		return nil, false
+	}
+
+	r.hits++
+	entry := elem.Value.(*registryEntry)
+	entry.lastAccess = r.now()
+	r.order.MoveToFront(elem)
+	if pin {
+		entry.agent.Pin()
+	}
+	return entry.agent, true
+}
+
+// Put inserts or replaces an agent, evicting the least recently used
+// agents if the registry is over capacity
+func (r *Registry) Put(agent *Agent) {
+	r.mu.Lock()
+	evicted := r.putLocked(agent)
+	r.mu.Unlock()
+
+	r.notifyEvicted(evicted, "capacity")
+}
+
+// GetOrPut returns the agent already registered under the same ID, or
+// inserts and returns the given agent if there is none
+func (r *Registry) GetOrPut(agent *Agent) *Agent {
+	return r.getOrPut(agent, false)
+}
+
+// AcquireOrPut is GetOrPut that also pins the returned agent
+func (r *Registry) AcquireOrPut(agent *Agent) *Agent {
+	return r.getOrPut(agent, true)
+}
+
+func (r *Registry) getOrPut(agent *Agent, pin bool) *Agent {
+	r.mu.Lock()
+	if elem, ok := r.entries[agent.ID]; ok {
+		entry := elem.Value.(*registryEntry)
+		entry.lastAccess = r.now()
+		r.order.MoveToFront(elem)
+		if pin {
+			entry.agent.Pin()
+		}
+		r.mu.Unlock()
+		return entry.agent
+	}
+	if pin {
+		// Pin before inserting so the new agent can't be chosen for eviction
+		agent.Pin()
+	}
+	evicted := r.putLocked(agent)
+	r.mu.Unlock()
+
+	r.notifyEvicted(evicted, "capacity")
+	return agent
+}
+
+// WaitFlushed blocks while an evicted agent with the given ID is still being
+// flushed, so a fresh copy is only loaded from the database once its state is saved
+func (r *Registry) WaitFlushed(id string) {
+	r.mu.Lock()
+	done, ok := r.flushing[id]
+	r.mu.Unlock()
+
+	if ok {
+		<-done
+	}
+}
+
+// Delete removes an agent without running the eviction callback
+func (r *Registry) Delete(id string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if elem, ok := r.entries[id]; ok {
+		r.order.Remove(elem)
+		delete(r.entries, id)
+	}
+}
+
+// Len returns the number of agents currently in memory
+func (r *Registry) Len() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.order.Len()
+}
+
+// Stats returns a snapshot of the registry counters
+func (r *Registry) Stats() RegistryStats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return RegistryStats{
+		Size:      r.order.Len(),
+		MaxSize:   r.cfg.MaxSize,
+		Hits:      r.hits,
+		Misses:    r.misses,
+		Evictions: r.evictions,
+	}
+}
+
+// EvictIdle removes every unpinned agent that has not been accessed within
+// IdleTTL and returns the number evicted
+func (r *Registry) EvictIdle() int {
+	if r.cfg.IdleTTL <= 0 {
+		return 0
+	}
+
+	r.mu.Lock()
+	cutoff := r.now().Add(-r.cfg.IdleTTL)
+	var evicted []*Agent
+	for elem := r.order.Back(); elem != nil; {
+		entry := elem.Value.(*registryEntry)
+		if entry.lastAccess.After(cutoff) {
+			// Entries are ordered by access time, so everything in front is newer
+			break
+		}
+		prev := elem.Prev()
+		if !entry.agent.pinned() {
+			evicted = append(evicted, r.removeLocked(elem))
+		}
+		elem = prev
+	}
+	r.mu.Unlock()
+
+	r.notifyEvicted(evicted, "idle")
+	return len(evicted)
+}
+
+// Flush runs the eviction callback for every agent without removing it,
+// e.g. to persist state before shutdown
+func (r *Registry) Flush() {
+	r.mu.Lock()
+	agents := make([]*Agent, 0, r.order.Len())
+	for elem := r.order.Front(); elem != nil; elem = elem.Next() {
+		agents = append(agents, elem.Value.(*registryEntry).agent)
+	}
+	r.mu.Unlock()
+
+	if r.cfg.OnEvict == nil {
+		return
+	}
+	for _, agent := range agents {
+		r.cfg.OnEvict(agent)
+	}
+}
+
+// StartJanitor evicts idle agents every interval until the returned stop function is called
+func (r *Registry) StartJanitor(interval time.Duration) (stop func()) {
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				if n := r.EvictIdle(); n > 0 {
+					stats := r.Stats()
+					log.Printf("[AGENT_REGISTRY] Evicted %d idle agents (size=%d hits=%d misses=%d evictions=%d)",
+						n, stats.Size, stats.Hits, stats.Misses, stats.Evictions)
+				}
+			case <-done:
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() { once.Do(func() { close(done) }) }
+}
+
+func (r *Registry) putLocked(agent *Agent) []*Agent {
+	if elem, ok := r.entries[agent.ID]; ok {
+		entry := elem.Value.(*registryEntry)
+		entry.agent = agent
+		entry.lastAccess = r.now()
+		r.order.MoveToFront(elem)
+		return nil
+	}
+
+	r.entries[agent.ID] = r.order.PushFront(&registryEntry{agent: agent, lastAccess: r.now()})
+
+	// Evict from the least recently used end, skipping agents in use
+	var evicted []*Agent
+	for elem := r.order.Back(); elem != nil && r.cfg.MaxSize > 0 && r.order.Len() > r.cfg.MaxSize; {
+		prev := elem.Prev()
+		if !elem.Value.(*registryEntry).agent.pinned() {
+			evicted = append(evicted, r.removeLocked(elem))
+		}
+		elem = prev
+	}
+	return evicted
+}
+
+// removeLocked removes an entry for eviction. Until notifyEvicted has run the
+// eviction callback, WaitFlushed blocks for the agent's ID.
+func (r *Registry) removeLocked(elem *list.Element) *Agent {
+	entry := r.order.Remove(elem).(*registryEntry)
+	delete(r.entries, entry.agent.ID)
+	r.evictions++
+	if r.cfg.OnEvict != nil {
+		r.flushing[entry.agent.ID] = make(chan struct{})
+	}
+	return entry.agent
+}
+
+func (r *Registry) notifyEvicted(agents []*Agent, reason string) {
+	for _, agent := range agents {
+		log.Printf("[AGENT_EVICT] Evicting agent %s (%s)", agent.ID, reason)
+		if r.cfg.OnEvict == nil {
+			continue
+		}
+		r.cfg.OnEvict(agent)
+
+		r.mu.Lock()
+		if done, ok := r.flushing[agent.ID]; ok {
+			close(done)
+			delete(r.flushing, agent.ID)
+		}
+		r.mu.Unlock()
+	}
+}
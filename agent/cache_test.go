@@ -0,0 +1,140 @@
+package agent
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestRegistryEvictsLeastRecentlyUsed(t *testing.T) {
+	var evicted []string
+	r := NewRegistry(RegistryConfig{
+		MaxSize: 2,
+		OnEvict: func(a *Agent) { evicted = append(evicted, a.ID) },
+	})
+
+	r.Put(&Agent{ID: "a"})
+	r.Put(&Agent{ID: "b"})
+	r.Get("a") // "b" is now least recently used
+	r.Put(&Agent{ID: "c"})
+
+	if _, ok := r.Get("b"); ok {
+		t.Error("expected b to be evicted")
+	}
+	if _, ok := r.Get("a"); !ok {
+		t.Error("expected a to remain")
+	}
+	if !slices.Equal(evicted, []string{"b"}) {
+		t.Errorf("expected eviction callback for [b], got %v", evicted)
+	}
+
+	stats := r.Stats()
+	if stats.Size != 2 || stats.Hits != 2 || stats.Misses != 1 || stats.Evictions != 1 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
+
+func TestRegistryEvictsIdleAgents(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	var evicted []string
+	r := NewRegistry(RegistryConfig{
+		IdleTTL: time.Hour,
+		OnEvict: func(a *Agent) { evicted = append(evicted, a.ID) },
+	})
+	r.now = func() time.Time { return now }
+
+	r.Put(&Agent{ID: "old"})
+	now = now.Add(45 * time.Minute)
+	r.Put(&Agent{ID: "new"})
+	now = now.Add(30 * time.Minute)
+
+	if n := r.EvictIdle(); n != 1 {
+		t.Fatalf("expected 1 idle eviction, got %d", n)
+	}
+	if !slices.Equal(evicted, []string{"old"}) {
+		t.Errorf("expected eviction callback for [old], got %v", evicted)
+	}
+	if r.Len() != 1 {
+		t.Errorf("expected 1 agent left, got %d", r.Len())
+	}
+}
+
+func TestRegistryDeleteSkipsCallback(t *testing.T) {
+	called := false
+	r := NewRegistry(RegistryConfig{OnEvict: func(*Agent) { called = true }})
+
+	r.Put(&Agent{ID: "a"})
+	r.Delete("a")
+
+	if r.Len() != 0 || called {
+		t.Errorf("expected silent removal, len=%d called=%v", r.Len(), called)
+	}
+}
+
+func TestRegistryKeepsPinnedAgents(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	var evicted []string
+	r := NewRegistry(RegistryConfig{
+		MaxSize: 1,
+		IdleTTL: time.Hour,
+		OnEvict: func(a *Agent) { evicted = append(evicted, a.ID) },
+	})
+	r.now = func() time.Time { return now }
+
+	busy := r.AcquireOrPut(&Agent{ID: "busy"})
+	r.Put(&Agent{ID: "other"}) // Over capacity, but "busy" is mid-turn
+
+	if _, ok := r.Get("busy"); !ok {
+		t.Fatal("pinned agent was evicted for capacity")
+	}
+	now = now.Add(2 * time.Hour)
+	r.EvictIdle()
+	if _, ok := r.Get("busy"); !ok {
+		t.Fatal("pinned agent was evicted for idleness")
+	}
+	if !slices.Equal(evicted, []string{"other"}) {
+		t.Errorf("expected eviction callback for [other], got %v", evicted)
+	}
+
+	busy.Unpin()
+	now = now.Add(2 * time.Hour)
+	r.EvictIdle()
+	if _, ok := r.Get("busy"); ok {
+		t.Error("expected the agent to be evictable once unpinned")
+	}
+}
+
+func TestRegistryWaitFlushedBlocksUntilEvictionCallbackReturns(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{})
+	r := NewRegistry(RegistryConfig{
+		MaxSize: 1,
+		OnEvict: func(*Agent) {
+			close(started)
+			<-release
+		},
+	})
+
+	r.Put(&Agent{ID: "a"})
+	go r.Put(&Agent{ID: "b"}) // Evicts "a", whose flush blocks until released
+	<-started
+
+	waited := make(chan struct{})
+	go func() {
+		r.WaitFlushed("a")
+		close(waited)
+	}()
+
+	select {
+	case <-waited:
+		t.Fatal("WaitFlushed returned while the agent was still being flushed")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	close(release)
+	select {
+	case <-waited:
+	case <-time.After(time.Second):
+		t.Fatal("WaitFlushed did not return after the flush finished")
+	}
+}
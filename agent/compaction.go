@@ -0,0 +1,60 @@
+package agent
+
+import (
+	"strings"
+
+	"agent/llm"
+)
+
+// memoryHeader introduces the rolling summary attached to the end of the system prompt
+const memoryHeader = "\n\n[CONVERSATION MEMORY - what happened earlier in this interrogation, remember it as your own]:\n"
+
+// EstimateTokens roughly counts the tokens in messages at four characters per token
+func EstimateTokens(messages []llm.Message) int {
+	chars := 0
+	for _, msg := range messages {
+		chars += len(msg.Content)
+	}
+	return (chars + 3) / 4
+}
+
+// WithMemory attaches the summary to the system prompt, replacing any summary
+// attached before
+func WithMemory(systemPrompt, summary string) string {
+	if i := strings.Index(systemPrompt, memoryHeader); i >= 0 {
+		systemPrompt = systemPrompt[:i]
+	}
+	if summary == "" {
+		return systemPrompt
+	}
+	return systemPrompt + memoryHeader + summary
+}
+
+// NeedsCompaction reports whether the turns after the system prompt are over the
+// token budget. A budget of 0 disables compaction. The caller must hold the agent lock.
+func (a *Agent) NeedsCompaction(budget int) bool {
+	return budget > 0 && len(a.History) > 1 && EstimateTokens(a.History[1:]) > budget
+}
+
+// CompactableMessages returns how many of the oldest turn messages can be folded
+// into the summary while the most recent keep messages stay verbatim. Only whole
+// turns are folded, so the kept history always starts with a player message.
+// The caller must hold the agent lock.
+func (a *Agent) CompactableMessages(keep int) int {
+	n := len(a.History) - 1 - keep
+	for n > 0 && 1+n < len(a.History) && a.History[1+n].Role != llm.RoleUser {
+		n--
+	}
+	return max(n, 0)
+}
+
+// ApplySummary replaces the oldest n turn messages with summary, which covers every
+// conversation message up to throughIndex. The caller must hold the agent lock.
+func (a *Agent) ApplySummary(summary string, n, throughIndex int) {
+	a.Summary = summary
+	a.SummaryThroughIndex = throughIndex
+
+	history := make([]llm.Message, 0, len(a.History)-n)
+	history = append(history, llm.NewMessage(a.History[0].Role, WithMemory(a.History[0].Content, summary)))
+	a.History = append(history, a.History[1+n:]...)
+}
@@ -0,0 +1,68 @@
+package agent
+
+import (
+	"strings"
+	"testing"
+
+	"agent/llm"
+)
+
+func newTurns(n int) []llm.Message {
+	history := []llm.Message{llm.NewMessage(llm.RoleModel, "You are Agnes.")}
+	for i := 0; i < n; i++ {
+		history = append(history,
+			llm.NewMessage(llm.RoleUser, "Where were you?"),
+			llm.NewMessage(llm.RoleModel, `{"reply": "At home."}`))
+	}
+	return history
+}
+
+func TestCompactableMessagesFoldsWholeTurns(t *testing.T) {
+	a := &Agent{History: newTurns(5)}
+
+	if n := a.CompactableMessages(4); n != 6 {
+		t.Errorf("expected 6 foldable messages keeping 4, got %d", n)
+	}
+	// Keeping an odd number would split a turn, so one more message is kept
+	if n := a.CompactableMessages(3); n != 6 {
+		t.Errorf("expected 6 foldable messages keeping 3, got %d", n)
+	}
+	if n := a.CompactableMessages(20); n != 0 {
+		t.Errorf("expected nothing to fold when keeping everything, got %d", n)
+	}
+}
+
+func TestApplySummaryReplacesOldTurnsAndMemory(t *testing.T) {
+	a := &Agent{History: newTurns(5), NextIndex: 11}
+
+	a.ApplySummary("- Agnes said she was at home.", 6, 6)
+	if len(a.History) != 5 || a.History[1].Role != llm.RoleUser {
+		t.Fatalf("expected system prompt plus 2 kept turns, got %d messages", len(a.History))
+	}
+	if !strings.HasPrefix(a.History[0].Content, "You are Agnes.") || !strings.Contains(a.History[0].Content, "at home") {
+		t.Errorf("expected the summary to be attached to the system prompt, got %q", a.History[0].Content)
+	}
+	if a.SummaryThroughIndex != 6 {
+		t.Errorf("expected summary through index 6, got %d", a.SummaryThroughIndex)
+	}
+
+	a.ApplySummary("- Agnes admitted she lied.", 2, 8)
+	if strings.Contains(a.History[0].Content, "at home") || strings.Count(a.History[0].Content, memoryHeader) != 1 {
+		t.Errorf("expected the new summary to replace the old one, got %q", a.History[0].Content)
+	}
+}
+
+func TestNeedsCompactionIgnoresSystemPrompt(t *testing.T) {
+	a := &Agent{History: []llm.Message{llm.NewMessage(llm.RoleModel, strings.Repeat("story ", 10000))}}
+	if a.NeedsCompaction(100) {
+		t.Error("expected the system prompt alone not to trigger compaction")
+	}
+
+	a.History = append(a.History, newTurns(20)[1:]...)
+	if !a.NeedsCompaction(100) {
+		t.Error("expected a long conversation to need compaction")
+	}
+	if a.NeedsCompaction(0) {
+		t.Error("expected a zero budget to disable compaction")
+	}
+}
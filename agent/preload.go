@@ -0,0 +1,161 @@
+package agent
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"agent/db"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// PreloadConfig controls the startup warm-up of the agent registry
+type PreloadConfig struct {
+	Window  time.Duration // Only agents with messages within this window are loaded
+	Limit   int           // Maximum number of agents to load (0 disables preloading)
+	Workers int           // Number of agents loaded concurrently
+}
+
+// Seams for tests; production code always uses the database-backed versions
+var (
+	loadAgent        = LoadAgentFromDatabase
+	findActiveAgents = findRecentlyActiveAgents
+)
+
+// PreloadProgress reports how far a warm-up has got
+type PreloadProgress struct {
+	Total   int `json:"total"`
+	Loaded  int `json:"loaded"`
+	Skipped int `json:"skipped"` // Already in memory when the worker reached them
+	Failed  int `json:"failed"`
+}
+
+// Done returns how many agents have been processed so far
+func (p PreloadProgress) Done() int {
+	return p.Loaded + p.Skipped + p.Failed
+}
+
+// PreloadActiveAgents loads the most recently active agents into AgentRegistry
+// using a bounded worker pool. onProgress, if set, is called after each agent
+// is processed. It returns the final progress once every worker has finished.
+func PreloadActiveAgents(ctx context.Context, cfg PreloadConfig, onProgress func(PreloadProgress)) PreloadProgress {
+	var progress PreloadProgress
+
+	limit := cfg.Limit
+	if maxSize := AgentRegistry.Stats().MaxSize; maxSize > 0 && limit > maxSize {
+		// Loading more than fits would just evict the agents we loaded first
+		limit = maxSize
+	}
+	if limit <= 0 {
+		return progress
+	}
+	workers := cfg.Workers
+	if workers <= 0 {
+		workers = 1
+	}
+
+	log.Printf("[AGENT_PRELOAD] Loading up to %d agents active within the last %s", limit, cfg.Window)
+
+	agentIDs, err := findActiveAgents(ctx, time.Now().Add(-cfg.Window), limit)
+	if err != nil {
+		log.Printf("[AGENT_PRELOAD_ERROR] Failed to find recent agents: %v", err)
+		return progress
+	}
+	progress.Total = len(agentIDs)
+
+	jobs := make(chan string)
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+
+	record := func(update func(*PreloadProgress)) {
+		mu.Lock()
+		update(&progress)
+		snapshot := progress
+		mu.Unlock()
+
+		if onProgress != nil {
+			onProgress(snapshot)
+		}
+	}
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for agentID := range jobs {
+				if _, ok := AgentRegistry.Get(agentID); ok {
+					record(func(p *PreloadProgress) { p.Skipped++ })
+					continue
+				}
+
+				AgentRegistry.WaitFlushed(agentID)
+				loaded, err := loadAgent(ctx, agentID)
+				if err != nil {
+					record(func(p *PreloadProgress) { p.Failed++ })
+					continue
+				}
+
+				AgentRegistry.GetOrPut(loaded)
+				record(func(p *PreloadProgress) { p.Loaded++ })
+			}
+		}()
+	}
+
+feed:
+	for _, agentID := range agentIDs {
+		select {
+		case jobs <- agentID:
+		case <-ctx.Done():
+			log.Printf("[AGENT_PRELOAD] Warm-up cancelled: %v", ctx.Err())
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	log.Printf("[AGENT_PRELOAD_SUCCESS] Preloaded %d of %d active agents (%d already loaded, %d failed)",
+		progress.Loaded, progress.Total, progress.Skipped, progress.Failed)
+
+	return progress
+}
+
+// findRecentlyActiveAgents returns the IDs of agents with messages since cutoff,
+// most recently active first
+func findRecentlyActiveAgents(ctx context.Context, cutoff time.Time, limit int) ([]string, error) {
+	conversationCollection := db.GetCollection("conversations")
+	pipeline := []bson.M{
+		{"$match": bson.M{
+			"timestamp": bson.M{"$gte": cutoff},
+		}},
+		{"$group": bson.M{
+			"_id":         "$agent_id",
+			"last_active": bson.M{"$max": "$timestamp"},
+		}},
+		{"$sort": bson.M{"last_active": -1}},
+		{"$limit": limit},
+	}
+
+	cursor, err := conversationCollection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var results []struct {
+		ID primitive.ObjectID `bson:"_id"`
+	}
+	if err := cursor.All(ctx, &results); err != nil {
+		return nil, err
+	}
+
+	agentIDs := make([]string, 0, len(results))
+	for _, result := range results {
+		agentIDs = append(agentIDs, result.ID.Hex())
+	}
+	return agentIDs, nil
+}
@@ -0,0 +1,120 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPreloadActiveAgents(t *testing.T) {
+	tests := []struct {
+		name      string
+		cfg       PreloadConfig
+		maxSize   int
+		active    int             // Agents the activity query can return
+		inMemory  map[string]bool // Already in the registry before the warm-up
+		failing   map[string]bool // Agents the loader fails on
+		wantLimit int             // Limit passed to the activity query, 0 if it must not run
+		want      PreloadProgress
+	}{
+		{
+			name:      "disabled",
+			cfg:       PreloadConfig{Limit: 0, Workers: 4},
+			maxSize:   10,
+			active:    5,
+			wantLimit: 0,
+			want:      PreloadProgress{},
+		},
+		{
+			name:      "loads every active agent",
+			cfg:       PreloadConfig{Limit: 5, Workers: 2},
+			maxSize:   10,
+			active:    5,
+			wantLimit: 5,
+			want:      PreloadProgress{Total: 5, Loaded: 5},
+		},
+		{
+			name:      "limit capped to registry size",
+			cfg:       PreloadConfig{Limit: 50, Workers: 3},
+			maxSize:   4,
+			active:    10,
+			wantLimit: 4,
+			want:      PreloadProgress{Total: 4, Loaded: 4},
+		},
+		{
+			name:      "counts skipped and failed agents",
+			cfg:       PreloadConfig{Limit: 6, Workers: 0},
+			maxSize:   10,
+			active:    6,
+			inMemory:  map[string]bool{"agent-1": true},
+			failing:   map[string]bool{"agent-3": true, "agent-4": true},
+			wantLimit: 6,
+			want:      PreloadProgress{Total: 6, Loaded: 3, Skipped: 1, Failed: 2},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prevRegistry, prevLoad, prevFind := AgentRegistry, loadAgent, findActiveAgents
+			defer func() { AgentRegistry, loadAgent, findActiveAgents = prevRegistry, prevLoad, prevFind }()
+
+			AgentRegistry = NewRegistry(RegistryConfig{MaxSize: tt.maxSize})
+			for id := range tt.inMemory {
+				AgentRegistry.Put(&Agent{ID: id})
+			}
+
+			gotLimit := 0
+			findActiveAgents = func(ctx context.Context, cutoff time.Time, limit int) ([]string, error) {
+				gotLimit = limit
+				ids := []string{}
+				for i := 0; i < tt.active && i < limit; i++ {
+					ids = append(ids, fmt.Sprintf("agent-%d", i))
+				}
+				return ids, nil
+			}
+
+			var mu sync.Mutex
+			running, peak := 0, 0
+			loadAgent = func(ctx context.Context, agentID string) (*Agent, error) {
+				mu.Lock()
+				running++
+				peak = max(peak, running)
+				mu.Unlock()
+				defer func() {
+					mu.Lock()
+					running--
+					mu.Unlock()
+				}()
+
+				time.Sleep(5 * time.Millisecond)
+				if tt.failing[agentID] {
+					return nil, errors.New("load failed")
+				}
+				return &Agent{ID: agentID}, nil
+			}
+
+			var reported atomic.Int32
+			got := PreloadActiveAgents(context.Background(), tt.cfg, func(p PreloadProgress) { reported.Add(1) })
+
+			if got != tt.want {
+				t.Errorf("expected progress %+v, got %+v", tt.want, got)
+			}
+			if gotLimit != tt.wantLimit {
+				t.Errorf("expected activity query limit %d, got %d", tt.wantLimit, gotLimit)
+			}
+			if int(reported.Load()) != got.Done() {
+				t.Errorf("expected %d progress callbacks, got %d", got.Done(), reported.Load())
+			}
+			if workers := max(tt.cfg.Workers, 1); peak > workers {
+				t.Errorf("expected at most %d concurrent loads, got %d", workers, peak)
+			}
+			if size := AgentRegistry.Stats().Size; size != got.Loaded+got.Skipped {
+				t.Errorf("expected %d agents in the registry, got %d", got.Loaded+got.Skipped, size)
+			}
+		})
+	}
+}
@@ -5,87 +5,127 @@ import (
 	"fmt"
 	"log"
 	"strings"
-	"sync"
 	"time"
 
 	"agent/db"
 	dbModels "agent/db/models"
+	"agent/llm"
 	"agent/models"
 	"agent/prompts"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo/options"
-	"google.golang.org/genai"
 )
 
-var (
-	AgentRegistry = make(map[string]*Agent)
-	mu            sync.Mutex
-)
+// AgentRegistry holds the agents currently loaded in memory.
+// It is unbounded until InitRegistry installs the configured policy.
+var AgentRegistry = NewRegistry(RegistryConfig{})
+
+// InitRegistry replaces AgentRegistry with a bounded registry that flushes
+// agent state to the database on eviction
+func InitRegistry(maxSize int, idleTTL time.Duration) {
+	AgentRegistry = NewRegistry(RegistryConfig{
+		MaxSize: maxSize,
+		IdleTTL: idleTTL,
+		OnEvict: FlushAgentState,
+	})
+	log.Printf("[AGENT_REGISTRY] Initialized with max size %d and idle TTL %s", maxSize, idleTTL)
+}
 
+// GetAgentByID returns the agent with the given ID, loading it from the
+// database if it isn't in memory. The agent isn't pinned, so use AcquireAgent
+// for anything that changes it.
 func GetAgentByID(id string) (*Agent, bool) {
-	mu.Lock()
-	agent, ok := AgentRegistry[id]
-	mu.Unlock()
+	return getAgent(id, false)
+}
+
+// AcquireAgent is GetAgentByID that pins the agent in the registry for a turn.
+// The caller must Unpin it when the turn, including any background work it
+// pinned separately, no longer needs this instance.
+func AcquireAgent(id string) (*Agent, bool) {
+	return getAgent(id, true)
+}
+
+func getAgent(id string, pin bool) (*Agent, bool) {
+	registry := AgentRegistry
 
 	// If agent is in memory, return it
-	if ok {
+	if agent, ok := registry.get(id, pin); ok {
 		log.Printf("[AGENT_GET] Agent %s found in memory", id)
 		return agent, true
 	}
 
-	// Agent not in memory, try to load from database
+	// Agent not in memory. If it was just evicted, its state may still be
+	// on its way to the database; load only once it's there.
+	registry.WaitFlushed(id)
 	log.Printf("[AGENT_GET] Agent %s not in memory, loading from database", id)
-	loadedAgent, err := LoadAgentFromDatabase(id)
+	loadedAgent, err := LoadAgentFromDatabase(context.Background(), id)
 	if err != nil {
 		log.Printf("[AGENT_GET_ERROR] Failed to load agent %s from database: %v", id, err)
 		return nil, false
 	}
 
-	// Add to registry for future requests
-	mu.Lock()
-	AgentRegistry[id] = loadedAgent
-	mu.Unlock()
+	// Add to registry for future requests. If a concurrent request loaded the
+	// same agent first, use that instance so both share one lock.
+	return registry.getOrPut(loadedAgent, pin), true
+}
+
+// FlushAgentState persists the agent's in-memory reveal state to the database
+func FlushAgentState(agent *Agent) {
+	agent.Lock()
+	defer agent.Unlock()
 
-	return loadedAgent, true
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := db.UpdateAgentReveals(ctx, agent.ID, agent.RevealedEvidenceIDs, agent.RevealedLocationIDs); err != nil {
+		log.Printf("[AGENT_FLUSH_ERROR] Failed to flush reveals for agent %s: %v", agent.ID, err)
+	}
+	if err := db.UpdateAgentStanding(ctx, agent.ID, agent.Reputation, agent.Intimidation, agent.PresentedEvidenceIDs); err != nil {
+		log.Printf("[AGENT_FLUSH_ERROR] Failed to flush standing for agent %s: %v", agent.ID, err)
+	}
 }
 
 // SpawnAgentWithCharacterAndID creates a new agent with a specific ID and character-specific system prompt
-func SpawnAgentWithCharacterAndID(agentID, systemPrompt, storyContext, storyID, characterID, characterName, personality string, evidenceIDs []string, locationIDs []string) {
+func SpawnAgentWithCharacterAndID(agentID, systemPrompt, storyContext, storyID, storyCollection, sessionID, playerID, characterID, characterName, personality string, evidenceIDs []string, locationIDs []string, standing Standing) {
 	// Combine system prompt and story context into one comprehensive system prompt
 	fullSystemPrompt := fmt.Sprintf("%s\n\n[STORY CONTEXT FOR REFERENCE]:\n%s", systemPrompt, storyContext)
 
 	// Create system content as the initial state
-	systemContent := genai.NewContentFromText(fullSystemPrompt, genai.RoleModel)
+	systemContent := llm.NewMessage(llm.RoleModel, fullSystemPrompt)
 
 	agent := &Agent{
-		ID:                  agentID,
-		History:             []*genai.Content{systemContent},
-		StoryID:             storyID,
-		CharacterID:         characterID,
-		CharacterName:       characterName,
-		Personality:         personality,
-		HoldsEvidenceIDs:    evidenceIDs,
-		KnowsLocationIDs:    locationIDs,
-		RevealedEvidenceIDs: make(map[string]bool),
-		RevealedLocationIDs: make(map[string]bool),
+		ID:                   agentID,
+		History:              []llm.Message{systemContent},
+		StoryID:              storyID,
+		StoryCollection:      storyCollection,
+		SessionID:            sessionID,
+		PlayerID:             playerID,
+		CharacterID:          characterID,
+		CharacterName:        characterName,
+		Personality:          personality,
+		HoldsEvidenceIDs:     evidenceIDs,
+		KnowsLocationIDs:     locationIDs,
+		RevealedEvidenceIDs:  make(map[string]bool),
+		RevealedLocationIDs:  make(map[string]bool),
+		PresentedEvidenceIDs: make(map[string]bool),
+		Reputation:           standing.Reputation,
+		Intimidation:         standing.Intimidation,
+		NextIndex:            1, // Index 0 is the system prompt
 	}
 
-	mu.Lock()
-	AgentRegistry[agentID] = agent
-	mu.Unlock()
+	AgentRegistry.Put(agent)
 }
 
 func DeleteAgent(id string) {
-	mu.Lock()
-	defer mu.Unlock()
-	delete(AgentRegistry, id)
+	AgentRegistry.Delete(id)
 }
 
-// LoadAgentFromDatabase loads an agent and its conversation history from the database
-func LoadAgentFromDatabase(agentID string) (*Agent, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+// LoadAgentFromDatabase loads an agent and its conversation history from the database.
+// The load is bounded by a 30 second timeout on top of ctx.
+func LoadAgentFromDatabase(ctx context.Context, agentID string) (*Agent, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
 	defer cancel()
 
 	// Convert string ID to ObjectID
@@ -108,17 +148,23 @@ func LoadAgentFromDatabase(agentID string) (*Agent, error) {
 
 	// Initialize the agent with basic info
 	agent := &Agent{
-		ID:                  agentID,
-		History:             []*genai.Content{},
-		StoryID:             agentDoc.StoryID.Hex(),
-		CharacterID:         agentDoc.CharacterID,
-		CharacterName:       agentDoc.CharacterName,
-		Personality:         agentDoc.Personality,
-		HoldsEvidenceIDs:    agentDoc.HoldsEvidenceIDs,
-		KnowsLocationIDs:    agentDoc.KnowsLocationIDs,
-		RevealedEvidenceIDs: agentDoc.RevealedEvidenceIDs,
-		RevealedLocationIDs: agentDoc.RevealedLocationIDs,
-		LoadedFromDB:        true, // Mark as loaded from DB
+		ID:                   agentID,
+		History:              []llm.Message{},
+		StoryID:              agentDoc.StoryID.Hex(),
+		StoryCollection:      agentDoc.StoryCollection,
+		SessionID:            agentDoc.SessionID,
+		PlayerID:             agentDoc.PlayerID,
+		CharacterID:          agentDoc.CharacterID,
+		CharacterName:        agentDoc.CharacterName,
+		Personality:          agentDoc.Personality,
+		HoldsEvidenceIDs:     agentDoc.HoldsEvidenceIDs,
+		KnowsLocationIDs:     agentDoc.KnowsLocationIDs,
+		RevealedEvidenceIDs:  agentDoc.RevealedEvidenceIDs,
+		RevealedLocationIDs:  agentDoc.RevealedLocationIDs,
+		PresentedEvidenceIDs: agentDoc.PresentedEvidenceIDs,
+		Reputation:           agentDoc.Reputation,
+		Intimidation:         agentDoc.Intimidation,
+		LoadedFromDB:         true, // Mark as loaded from DB
 	}
 
 	// Initialize maps if nil
@@ -128,15 +174,32 @@ func LoadAgentFromDatabase(agentID string) (*Agent, error) {
 	if agent.RevealedLocationIDs == nil {
 		agent.RevealedLocationIDs = make(map[string]bool)
 	}
+	if agent.PresentedEvidenceIDs == nil {
+		agent.PresentedEvidenceIDs = make(map[string]bool)
+	}
+
+	// A compacted agent only needs the system prompt and the turns after its summary
+	conversationFilter := bson.M{"agent_id": objID}
+	summary, err := db.GetLatestConversationSummary(ctx, objID)
+	if err != nil {
+		log.Printf("[AGENT_LOAD_WARNING] Failed to load conversation summary for agent %s: %v. Loading full history.", agentID, err)
+	} else if summary != nil {
+		agent.Summary = summary.Summary
+		agent.SummaryThroughIndex = summary.ThroughIndex
+		conversationFilter["$or"] = []bson.M{
+			{"index": 0},
+			{"index": bson.M{"$gt": summary.ThroughIndex}},
+		}
+	}
 
 	// Load conversation history
 	conversationCollection := db.GetCollection("conversations")
 
 	// Set find options to sort by index
-	findOptions := options.Find().SetSort(bson.D{{"index", 1}})
+	findOptions := options.Find().SetSort(bson.D{{Key: "index", Value: 1}})
 
 	cursor, err := conversationCollection.Find(ctx,
-		bson.M{"agent_id": objID},
+		conversationFilter,
 		findOptions,
 	)
 	if err != nil {
@@ -153,7 +216,15 @@ func LoadAgentFromDatabase(agentID string) (*Agent, error) {
 		return agent, nil
 	}
 
-	// Convert conversation documents to genai.Content
+	// Continue numbering after the last persisted or summarized message
+	if len(conversations) > 0 {
+		agent.NextIndex = conversations[len(conversations)-1].Index + 1
+	} else {
+		agent.NextIndex = 1
+	}
+	agent.NextIndex = max(agent.NextIndex, agent.SummaryThroughIndex+1)
+
+	// Convert conversation documents to llm messages
 	for i, conv := range conversations {
 		// Skip empty content messages - Gemini doesn't accept them
 		if strings.TrimSpace(conv.Content) == "" {
@@ -161,11 +232,9 @@ func LoadAgentFromDatabase(agentID string) (*Agent, error) {
 			continue
 		}
 
-		var role genai.Role
+		role := llm.RoleModel
 		if conv.Role == "user" {
-			role = genai.RoleUser
-		} else {
-			role = genai.RoleModel
+			role = llm.RoleUser
 		}
 
 		// Check if this is the system prompt (first model message)
@@ -174,11 +243,15 @@ func LoadAgentFromDatabase(agentID string) (*Agent, error) {
 
 			// Fetch the story
 			var story models.Story
-			storyCollection := db.GetCollection("stories")
+			storyCollectionName := agentDoc.StoryCollection
+			if storyCollectionName == "" {
+				storyCollectionName = "stories"
+			}
+			storyCollection := db.GetCollection(storyCollectionName)
 			err := storyCollection.FindOne(ctx, bson.M{"_id": agentDoc.StoryID}).Decode(&story)
 			if err != nil {
 				log.Printf("[AGENT_LOAD_REGEN_ERROR] Failed to fetch story: %v. Using existing prompt.", err)
-				agent.History = append(agent.History, genai.NewContentFromText(conv.Content, role))
+				agent.History = append(agent.History, llm.NewMessage(role, conv.Content))
 				continue
 			}
 
@@ -193,7 +266,7 @@ func LoadAgentFromDatabase(agentID string) (*Agent, error) {
 
 			if character == nil {
 				log.Printf("[AGENT_LOAD_REGEN_ERROR] Character %s not found. Using existing prompt.", agentDoc.CharacterID)
-				agent.History = append(agent.History, genai.NewContentFromText(conv.Content, role))
+				agent.History = append(agent.History, llm.NewMessage(role, conv.Content))
 				continue
 			}
 
@@ -205,7 +278,7 @@ func LoadAgentFromDatabase(agentID string) (*Agent, error) {
 				systemPrompt, story.Story.FullStory)
 
 			// Use the regenerated prompt
-			agent.History = append(agent.History, genai.NewContentFromText(fullSystemPrompt, role))
+			agent.History = append(agent.History, llm.NewMessage(role, fullSystemPrompt))
 
 			// Update in database asynchronously
 			go func(agentID primitive.ObjectID, newPrompt string) {
@@ -236,62 +309,16 @@ func LoadAgentFromDatabase(agentID string) (*Agent, error) {
 			log.Printf("[AGENT_LOAD_REGEN_SUCCESS] Regenerated system prompt for agent %s", agentDoc.CharacterName)
 		} else {
 			// Regular message, append as normal
-			agent.History = append(agent.History, genai.NewContentFromText(conv.Content, role))
+			agent.History = append(agent.History, llm.NewMessage(role, conv.Content))
 		}
 	}
 
-	log.Printf("[AGENT_LOAD_SUCCESS] Loaded agent %s with %d conversation messages", agentDoc.CharacterName, len(agent.History))
-
-	return agent, nil
-}
-
-// PreloadActiveAgents can be called on server startup to load recently active agents into memory
-// This is optional but can improve initial response times after server restart
-func PreloadActiveAgents(hoursAgo int) {
-	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
-	defer cancel()
-
-	// Calculate cutoff time
-	cutoffTime := time.Now().Add(-time.Duration(hoursAgo) * time.Hour)
-
-	log.Printf("[AGENT_PRELOAD] Loading agents active within last %d hours", hoursAgo)
-
-	// Find agents with recent conversations
-	conversationCollection := db.GetCollection("conversations")
-	pipeline := []bson.M{
-		{"$match": bson.M{
-			"timestamp": bson.M{"$gte": cutoffTime},
-		}},
-		{"$group": bson.M{
-			"_id": "$agent_id",
-		}},
-		{"$limit": 50}, // Limit to prevent memory issues
+	// Reattach the rolling summary to the (possibly regenerated) system prompt
+	if agent.Summary != "" && len(agent.History) > 0 {
+		agent.History[0].Content = WithMemory(agent.History[0].Content, agent.Summary)
 	}
 
-	cursor, err := conversationCollection.Aggregate(ctx, pipeline)
-	if err != nil {
-		log.Printf("[AGENT_PRELOAD_ERROR] Failed to find recent agents: %v", err)
-		return
-	}
-	defer cursor.Close(ctx)
-
-	var results []struct {
-		ID primitive.ObjectID `bson:"_id"`
-	}
-
-	if err := cursor.All(ctx, &results); err != nil {
-		log.Printf("[AGENT_PRELOAD_ERROR] Failed to decode agent IDs: %v", err)
-		return
-	}
-
-	// Load each agent
-	loaded := 0
-	for _, result := range results {
-		agentID := result.ID.Hex()
-		if _, err := LoadAgentFromDatabase(agentID); err == nil {
-			loaded++
-		}
-	}
+	log.Printf("[AGENT_LOAD_SUCCESS] Loaded agent %s with %d conversation messages", agentDoc.CharacterName, len(agent.History))
 
-	log.Printf("[AGENT_PRELOAD_SUCCESS] Preloaded %d active agents into memory", loaded)
+	return agent, nil
 }
@@ -0,0 +1,90 @@
+package agent
+
+import (
+	"slices"
+
+	"agent/models"
+)
+
+// Message tones recognised by the standing rules
+const (
+	ToneFriendly    = "friendly"
+	ToneRespectful  = "respectful"
+	ToneNeutral     = "neutral"
+	ToneRude        = "rude"
+	ToneThreatening = "threatening"
+)
+
+// Tones lists every tone the classifier may return
+var Tones = []string{ToneFriendly, ToneRespectful, ToneNeutral, ToneRude, ToneThreatening}
+
+const (
+	minStanding = 0
+	maxStanding = 100
+)
+
+// Standing is the character's current attitude towards the investigator
+type Standing struct {
+	Reputation   int `json:"reputation"`
+	Intimidation int `json:"intimidation"`
+}
+
+// toneEffects maps a message tone to its reputation and intimidation deltas
+var toneEffects = map[string]Standing{
+	ToneFriendly:    {Reputation: 5},
+	ToneRespectful:  {Reputation: 3},
+	ToneNeutral:     {},
+	ToneRude:        {Reputation: -5, Intimidation: 2},
+	ToneThreatening: {Reputation: -5, Intimidation: 10},
+}
+
+// Standing returns the agent's current reputation and intimidation.
+// The caller must hold the agent lock.
+func (a *Agent) Standing() Standing {
+	return Standing{Reputation: a.Reputation, Intimidation: a.Intimidation}
+}
+
+// ApplyTone adjusts standing for the tone of the player's message.
+// Unknown tones are treated as neutral. The caller must hold the agent lock.
+func (a *Agent) ApplyTone(tone string) {
+	a.adjustStanding(toneEffects[tone])
+}
+
+// ApplyPresentedEvidence adjusts standing for evidence the player shows the
+// character: any evidence proves the investigator did their homework, and
+// critical evidence puts the character under real pressure. Each piece counts
+// once per character, and evidence the character holds itself never counts.
+// The caller must hold the agent lock.
+func (a *Agent) ApplyPresentedEvidence(evidence []models.Evidence) {
+	if a.PresentedEvidenceIDs == nil {
+		a.PresentedEvidenceIDs = make(map[string]bool)
+	}
+	for _, e := range evidence {
+		if a.PresentedEvidenceIDs[e.ID] || slices.Contains(a.HoldsEvidenceIDs, e.ID) {
+			continue
+		}
+		a.PresentedEvidenceIDs[e.ID] = true
+
+		delta := Standing{Reputation: 2, Intimidation: 3}
+		if e.IsCritical {
+			delta.Intimidation = 10
+		}
+		a.adjustStanding(delta)
+	}
+}
+
+// CanReveal reports whether the character's standing meets both thresholds of
+// the evidence. A threshold of zero imposes no requirement.
+// The caller must hold the agent lock.
+func (a *Agent) CanReveal(e models.Evidence) bool {
+	return a.Reputation >= e.MinReputation && a.Intimidation >= e.MinIntimidation
+}
+
+func (a *Agent) adjustStanding(delta Standing) {
+	a.Reputation = clampStanding(a.Reputation + delta.Reputation)
+	a.Intimidation = clampStanding(a.Intimidation + delta.Intimidation)
+}
+
+func clampStanding(v int) int {
+	return max(minStanding, min(maxStanding, v))
+}
@@ -0,0 +1,200 @@
+// Package auth verifies the bearer tokens that identify players and issues
+// anonymous guest tokens.
+package auth
+
+import (
+	"agent/config"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// clockLeeway is how far token timestamps may be off before they are rejected
+const clockLeeway = 30 * time.Second
+
+// guestPrefix starts the player ID of every guest
+const guestPrefix = "guest_"
+
+// ErrGuestTokensDisabled is returned when guest tokens are requested without an HMAC secret to sign them
+var ErrGuestTokensDisabled = errors.New("guest tokens need AUTH_HMAC_SECRET")
+
+// Claims are the token claims the API reads. The subject is the player ID.
+type Claims struct {
+	Guest bool `json:"guest,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// Verifier checks player tokens signed with a shared HMAC secret (HS256/384/512)
+// or with a public key from a local JWKS file (RS256/384/512, ES256/384/512)
+type Verifier struct {
+	secret []byte
+	keys   map[string]crypto.PublicKey // JWKS keys by key ID
+	issuer string
+}
+
+// NewVerifier creates a verifier from an HMAC secret and/or a JWKS file. It
+// returns nil if neither is given, which leaves authentication disabled.
+func NewVerifier(secret, jwksPath, issuer string) (*Verifier, error) {
+	if secret == "" && jwksPath == "" {
+		return nil, nil
+	}
+
+	v := &Verifier{issuer: issuer}
+	if secret != "" {
+		v.secret = []byte(secret)
+	}
+	if jwksPath != "" {
+		keys, err := LoadJWKS(jwksPath)
+		if err != nil {
+			return nil, err
+		}
+		v.keys = keys
+	}
+	return v, nil
+}
+
+// Verify checks a token's signature, expiry and issuer and returns its player ID
+func (v *Verifier) Verify(token string) (string, error) {
+	var claims Claims
+	parsed, err := jwt.ParseWithClaims(token, &claims, v.key,
+		jwt.WithValidMethods(v.methods()),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(clockLeeway),
+		jwt.WithIssuer(v.issuer),
+	)
+	if err != nil {
+		return "", err
+	}
+	if !parsed.Valid || claims.Subject == "" {
+		return "", errors.New("token has no subject")
+	}
+	return claims.Subject, nil
+}
+
+// methods lists the signing algorithms the configured keys can verify, so a
+// token can't pick an algorithm its key wasn't meant for
+func (v *Verifier) methods() []string {
+	var methods []string
+	if v.secret != nil {
+		methods = append(methods, "HS256", "HS384", "HS512")
+	}
+	if len(v.keys) > 0 {
+		methods = append(methods, "RS256", "RS384", "RS512", "ES256", "ES384", "ES512")
+	}
+	return methods
+}
+
+// key picks the verification key for a token
+func (v *Verifier) key(token *jwt.Token) (any, error) {
+	if _, ok := token.Method.(*jwt.SigningMethodHMAC); ok {
+		return v.secret, nil
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	if key, ok := v.keys[kid]; ok {
+		return key, nil
+	}
+	// A JWKS with a single key doesn't need tokens to name it
+	if kid == "" && len(v.keys) == 1 {
+		for _, key := range v.keys {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown key ID %q", kid)
+}
+
+// IssueGuestToken signs a token for a new anonymous player
+func (v *Verifier) IssueGuestToken(ttl time.Duration) (token, playerID string, expiresAt time.Time, err error) {
+	if v.secret == nil {
+		return "", "", time.Time{}, ErrGuestTokensDisabled
+	}
+
+	id := make([]byte, 12)
+	if _, err := rand.Read(id); err != nil {
+		return "", "", time.Time{}, err
+	}
+	playerID = guestPrefix + hex.EncodeToString(id)
+
+	now := time.Now()
+	expiresAt = now.Add(ttl).Truncate(time.Second)
+	claims := Claims{
+		Guest: true,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   playerID,
+			Issuer:    v.issuer,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+	}
+	token, err = jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(v.secret)
+	if err != nil {
+		return "", "", time.Time{}, err
+	}
+	return token, playerID, expiresAt, nil
+}
+
+var (
+	defaultVerifier *Verifier
+	defaultMu       sync.RWMutex
+)
+
+// Init builds the verifier from AUTH_HMAC_SECRET, AUTH_JWKS_FILE and AUTH_ISSUER
+// and installs it as the default
+func Init() error {
+	v, err := NewVerifier(config.GetAuthHMACSecret(), config.GetAuthJWKSFile(), config.GetAuthIssuer())
+	if err != nil {
+		return err
+	}
+
+	SetDefault(v)
+	if v == nil {
+		log.Println("[AUTH] No AUTH_HMAC_SECRET or AUTH_JWKS_FILE set, player authentication is disabled")
+	} else {
+		log.Printf("[AUTH] Player authentication enabled (hmac=%v, jwks keys=%d)", v.secret != nil, len(v.keys))
+	}
+	return nil
+}
+
+// SetDefault replaces the verifier returned by Default
+func SetDefault(v *Verifier) {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	defaultVerifier = v
+}
+
+// Default returns the verifier installed by Init or SetDefault, or nil when
+// authentication is disabled
+func Default() *Verifier {
+	defaultMu.RLock()
+	defer defaultMu.RUnlock()
+	return defaultVerifier
+}
+
+type playerKey struct{}
+
+// WithPlayerID returns a context carrying the authenticated player ID
+func WithPlayerID(ctx context.Context, playerID string) context.Context {
+	return context.WithValue(ctx, playerKey{}, playerID)
+}
+
+// PlayerID returns the authenticated player ID, or "" when the request was not
+// authenticated because authentication is disabled
+func PlayerID(ctx context.Context) string {
+	id, _ := ctx.Value(playerKey{}).(string)
+	return id
+}
+
+// Owns reports whether the authenticated player may access a resource owned by
+// owner. Everything is accessible when authentication is disabled.
+func Owns(ctx context.Context, owner string) bool {
+	player := PlayerID(ctx)
+	return player == "" || player == owner
+}
@@ -0,0 +1,122 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestGuestTokenRoundTrip(t *testing.T) {
+	v, err := NewVerifier("secret", "", "case-api")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token, playerID, expiresAt, err := v.IssueGuestToken(time.Hour)
+	if err != nil {
+		t.Fatalf("IssueGuestToken returned error: %v", err)
+	}
+	if !strings.HasPrefix(playerID, guestPrefix) || time.Until(expiresAt) <= 0 {
+		t.Errorf("unexpected guest %q expiring %v", playerID, expiresAt)
+	}
+
+	got, err := v.Verify(token)
+	if err != nil || got != playerID {
+		t.Errorf("expected %q, got %q (%v)", playerID, got, err)
+	}
+
+	other, _ := NewVerifier("other-secret", "", "case-api")
+	if _, err := other.Verify(token); err == nil {
+		t.Error("expected a token signed with another secret to be rejected")
+	}
+	wrongIssuer, _ := NewVerifier("secret", "", "someone-else")
+	if _, err := wrongIssuer.Verify(token); err == nil {
+		t.Error("expected a token from another issuer to be rejected")
+	}
+}
+
+func TestVerifyRejectsBadTokens(t *testing.T) {
+	v, _ := NewVerifier("secret", "", "")
+	sign := func(claims jwt.Claims) string {
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("secret"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+
+	expired := sign(jwt.RegisteredClaims{Subject: "p1", ExpiresAt: jwt.NewNumericDate(time.Now().Add(-time.Hour))})
+	noExpiry := sign(jwt.RegisteredClaims{Subject: "p1"})
+	noSubject := sign(jwt.RegisteredClaims{ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour))})
+	unsigned, _ := jwt.NewWithClaims(jwt.SigningMethodNone, jwt.RegisteredClaims{
+		Subject: "p1", ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	}).SignedString(jwt.UnsafeAllowNoneSignatureType)
+
+	for name, token := range map[string]string{
+		"expired": expired, "no expiry": noExpiry, "no subject": noSubject, "unsigned": unsigned, "garbage": "not.a.token",
+	} {
+		if _, err := v.Verify(token); err == nil {
+			t.Errorf("%s: expected token to be rejected", name)
+		}
+	}
+}
+
+func TestVerifyWithJWKS(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwks, _ := json.Marshal(map[string]any{"keys": []map[string]string{{
+		"kty": "RSA",
+		"kid": "key-1",
+		"use": "sig",
+		"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}}})
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, jwks, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	v, err := NewVerifier("", path, "")
+	if err != nil {
+		t.Fatalf("NewVerifier returned error: %v", err)
+	}
+
+	claims := jwt.RegisteredClaims{Subject: "player-7", ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour))}
+	rs := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	rs.Header["kid"] = "key-1"
+	token, _ := rs.SignedString(key)
+	if got, err := v.Verify(token); err != nil || got != "player-7" {
+		t.Errorf("expected player-7, got %q (%v)", got, err)
+	}
+
+	// Without an HMAC secret, HS256 tokens must not be accepted with any key material
+	hs, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(""))
+	if _, err := v.Verify(hs); err == nil {
+		t.Error("expected an HS256 token to be rejected by a JWKS-only verifier")
+	}
+	if _, _, _, err := v.IssueGuestToken(time.Hour); err != ErrGuestTokensDisabled {
+		t.Errorf("expected guest tokens to be disabled, got %v", err)
+	}
+}
+
+func TestOwns(t *testing.T) {
+	if !Owns(context.Background(), "anyone") {
+		t.Error("expected everything to be accessible without authentication")
+	}
+	ctx := WithPlayerID(context.Background(), "p1")
+	if !Owns(ctx, "p1") || Owns(ctx, "p2") || Owns(ctx, "") {
+		t.Error("expected only p1's resources to be accessible")
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"agent/handlers"
+	"agent/models"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// apiClient talks to a running game server over its HTTP API, using the
+// server's own request and response types
+type apiClient struct {
+	baseURL    string
+	token      string // Player bearer token, if the server requires one
+	httpClient *http.Client
+}
+
+func newAPIClient(baseURL string) *apiClient {
+	return &apiClient{
+		baseURL: baseURL,
+		// Scoring with an ensemble can take a while
+		httpClient: &http.Client{Timeout: 90 * time.Second},
+	}
+}
+
+// apiError is a non-2xx response from the server
+type apiError struct {
+	Status  int
+	Message string
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("%d %s: %s", e.Status, http.StatusText(e.Status), e.Message)
+}
+
+// do sends body as JSON (if not nil) and decodes the JSON response into out
+func (c *apiClient) do(method, path string, body, out any) error {
+	var reqBody bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&reqBody).Encode(body); err != nil {
+			return err
+		}
+	}
+
+	req, err := http.NewRequest(method, c.baseURL+path, &reqBody)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		var errBody struct {
+			Error string `json:"error"`
+		}
+		var raw bytes.Buffer
+		raw.ReadFrom(resp.Body)
+		if json.Unmarshal(raw.Bytes(), &errBody) != nil || errBody.Error == "" {
+			errBody.Error = string(bytes.TrimSpace(raw.Bytes()))
+		}
+		return &apiError{Status: resp.StatusCode, Message: errBody.Error}
+	}
+
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func (c *apiClient) GuestToken() (*handlers.GuestTokenResponse, error) {
+	var resp handlers.GuestTokenResponse
+	err := c.do(http.MethodPost, "/auth/guest", nil, &resp)
+	return &resp, err
+}
+
+func (c *apiClient) Feed(limit int) (*handlers.FeedResponse, error) {
+	var feed handlers.FeedResponse
+	err := c.do(http.MethodGet, fmt.Sprintf("/feed?limit=%d", limit), nil, &feed)
+	return &feed, err
+}
+
+func (c *apiClient) Story(storyID string) (*models.PublicStory, error) {
+	var story models.PublicStory
+	err := c.do(http.MethodGet, "/story?id="+url.QueryEscape(storyID), nil, &story)
+	return &story, err
+}
+
+func (c *apiClient) CreateSession(playerID, storyID string) (*handlers.SessionResponse, error) {
+	var session handlers.SessionResponse
+	err := c.do(http.MethodPost, "/sessions", handlers.SessionRequest{PlayerID: playerID, StoryID: storyID}, &session)
+	return &session, err
+}
+
+func (c *apiClient) ResumeSession(playerID, storyID string) (*handlers.SessionResponse, error) {
+	var session handlers.SessionResponse
+	err := c.do(http.MethodPost, "/sessions/resume", handlers.SessionRequest{PlayerID: playerID, StoryID: storyID}, &session)
+	return &session, err
+}
+
+func (c *apiClient) Session(sessionID string) (*handlers.SessionResponse, error) {
+	var session handlers.SessionResponse
+	err := c.do(http.MethodGet, "/sessions/"+url.PathEscape(sessionID), nil, &session)
+	return &session, err
+}
+
+func (c *apiClient) Location(sessionID, locationID string) (*models.PublicLocation, error) {
+	var location models.PublicLocation
+	err := c.do(http.MethodGet, "/sessions/"+url.PathEscape(sessionID)+"/locations/"+url.PathEscape(locationID), nil, &location)
+	return &location, err
+}
+
+func (c *apiClient) Spawn(req handlers.SpawnRequest) (string, error) {
+	var resp handlers.SpawnResponse
+	err := c.do(http.MethodPost, "/spawn", req, &resp)
+	return resp.AgentID, err
+}
+
+func (c *apiClient) Message(req handlers.MessageRequest) (*handlers.MessageResponse, error) {
+	var resp handlers.MessageResponse
+	err := c.do(http.MethodPost, "/message", req, &resp)
+	return &resp, err
+}
+
+func (c *apiClient) UnlockContainer(req handlers.ContainerUnlockRequest) (*handlers.ContainerUnlockResponse, error) {
+	var resp handlers.ContainerUnlockResponse
+	err := c.do(http.MethodPost, "/containers/unlock", req, &resp)
+	return &resp, err
+}
+
+func (c *apiClient) Score(req handlers.ScoreRequest) (*handlers.ScoreResponse, error) {
+	var resp handlers.ScoreResponse
+	err := c.do(http.MethodPost, "/score", req, &resp)
+	return &resp, err
+}
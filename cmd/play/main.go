@@ -0,0 +1,474 @@
+// Command play is a terminal client for playing a case against a running server.
+// It goes through the public HTTP API, so a scripted run doubles as an
+// end-to-end smoke test:
+//
+//	go run ./cmd/play -server http://localhost:8080 -player writer-1
+//	go run ./cmd/play -token "$PLAYER_TOKEN"
+//	go run ./cmd/play -fail-fast < playthrough.txt
+//
+// Type "help" at the prompt for the list of commands.
+package main
+
+import (
+	"agent/handlers"
+	"agent/models"
+	"bufio"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const helpText = `Commands:
+  stories                       List stories from the feed
+  play <n|story_id>             Start a new session for a story
+  resume <n|story_id>           Resume your latest session for a story
+  status                        Show session progress
+  look                          Describe the current location
+  locations                     List unlocked locations
+  go <location_id>              Move to an unlocked location
+  talk <character_id>           Start talking to a character
+  say <message>                 Say something (plain text works too while talking)
+  present <evid,...> <message>  Show evidence to the character you are talking to
+  evidence                      List discovered evidence
+  open <container_id> <code>    Try a code on a container
+  accuse <char,...> [evid,...]  Set a formal accusation for your theory
+  theory <text>                 Submit your theory for scoring
+  help                          Show this help
+  quit                          Leave the game`
+
+// game holds the state of the current playthrough
+type game struct {
+	client   *apiClient
+	playerID string
+
+	stories    []handlers.FeedItem
+	story      *storyView
+	session    *handlers.SessionResponse
+	locationID string
+	characters map[string]string // Character ID -> agent ID for this session
+	talkingTo  string
+	accusation *handlers.Accusation
+}
+
+func main() {
+	server := flag.String("server", "http://localhost:8080", "base URL of the game server")
+	player := flag.String("player", "terminal-player", "player ID used for sessions when the server has authentication disabled")
+	token := flag.String("token", "", "player bearer token; without one a guest token is requested if the server issues them")
+	failFast := flag.Bool("fail-fast", false, "exit with status 1 on the first failed command (for scripted smoke tests)")
+	flag.Parse()
+
+	g := &game{
+		client:   newAPIClient(strings.TrimRight(*server, "/")),
+		playerID: *player,
+	}
+	g.client.token = *token
+	if *token != "" {
+		g.playerID = "" // The server takes the player ID from the token
+	} else if guest, err := g.client.GuestToken(); err == nil {
+		// Otherwise the server doesn't issue tokens and the -player ID is used as is
+		g.client.token = guest.Token
+		g.playerID = guest.PlayerID
+		fmt.Printf("Playing as guest %s\n", guest.PlayerID)
+	}
+
+	fmt.Println("Detective terminal. Type \"help\" for commands.")
+	scanner := bufio.NewScanner(os.Stdin)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for {
+		fmt.Print(g.prompt())
+		if !scanner.Scan() {
+			fmt.Println()
+			return
+		}
+
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if line == "quit" || line == "exit" {
+			return
+		}
+
+		if err := g.run(line); err != nil {
+			fmt.Printf("! %v\n", err)
+			if *failFast {
+				log.Fatalf("Command %q failed: %v", line, err)
+			}
+		}
+	}
+}
+
+func (g *game) prompt() string {
+	switch {
+	case g.session == nil:
+		return "> "
+	case g.talkingTo != "":
+		return fmt.Sprintf("[%s @ %s]> ", g.story.characterName(g.talkingTo), g.locationID)
+	default:
+		return fmt.Sprintf("[%s]> ", g.locationID)
+	}
+}
+
+// run executes one command line
+func (g *game) run(line string) error {
+	cmd, args, _ := strings.Cut(line, " ")
+	args = strings.TrimSpace(args)
+
+	switch cmd {
+	case "help":
+		fmt.Println(helpText)
+		return nil
+	case "stories":
+		return g.listStories()
+	case "play":
+		return g.start(args, false)
+	case "resume":
+		return g.start(args, true)
+	}
+
+	if g.session == nil {
+		if cmd == "say" {
+			return fmt.Errorf("pick a story first with \"play\"")
+		}
+		return fmt.Errorf("unknown command %q, or no story in progress (try \"help\")", cmd)
+	}
+
+	switch cmd {
+	case "status":
+		return g.status()
+	case "look":
+		g.look()
+		return nil
+	case "locations":
+		return g.locations()
+	case "go":
+		return g.goTo(args)
+	case "talk":
+		return g.talk(args)
+	case "say":
+		return g.say(args, nil)
+	case "present":
+		ids, message, _ := strings.Cut(args, " ")
+		if ids == "" {
+			return fmt.Errorf("usage: present <evid,...> <message>")
+		}
+		if message == "" {
+			message = "Take a look at this."
+		}
+		return g.say(message, splitIDs(ids))
+	case "evidence":
+		return g.evidence()
+	case "open":
+		containerID, code, _ := strings.Cut(args, " ")
+		return g.open(containerID, strings.TrimSpace(code))
+	case "accuse":
+		culprits, evidence, _ := strings.Cut(args, " ")
+		if culprits == "" {
+			return fmt.Errorf("usage: accuse <char,...> [evid,...]")
+		}
+		g.accusation = &handlers.Accusation{CulpritIDs: splitIDs(culprits), EvidenceIDs: splitIDs(evidence)}
+		fmt.Printf("Accusation set: %s. Submit it with \"theory\".\n", strings.Join(g.accusation.CulpritIDs, ", "))
+		return nil
+	case "theory":
+		return g.theory(args)
+	}
+
+	// Anything else is said to the character you are talking to
+	if g.talkingTo != "" {
+		return g.say(line, nil)
+	}
+	return fmt.Errorf("unknown command %q (try \"help\")", cmd)
+}
+
+func (g *game) listStories() error {
+	feed, err := g.client.Feed(50)
+	if err != nil {
+		return err
+	}
+	g.stories = feed.Stories
+
+	for i, s := range g.stories {
+		fmt.Printf("%2d. %s  (%s)\n", i+1, s.Title, s.ID)
+	}
+	if len(g.stories) == 0 {
+		fmt.Println("No stories available.")
+	}
+	return nil
+}
+
+// start begins or resumes a session for the story picked by number or ID
+func (g *game) start(arg string, resume bool) error {
+	storyID := arg
+	if n, err := strconv.Atoi(arg); err == nil {
+		if n < 1 || n > len(g.stories) {
+			return fmt.Errorf("no story %d, list them with \"stories\"", n)
+		}
+		storyID = g.stories[n-1].ID
+	}
+	if storyID == "" {
+		return fmt.Errorf("usage: play <n|story_id>")
+	}
+
+	public, err := g.client.Story(storyID)
+	if err != nil {
+		return err
+	}
+
+	var session *handlers.SessionResponse
+	if resume {
+		session, err = g.client.ResumeSession(g.playerID, storyID)
+	} else {
+		session, err = g.client.CreateSession(g.playerID, storyID)
+	}
+	if err != nil {
+		return err
+	}
+
+	g.story = newStoryView(public)
+	g.session = session
+	g.characters = session.Agents
+	if g.characters == nil {
+		g.characters = map[string]string{}
+	}
+	g.talkingTo = ""
+	g.accusation = nil
+	g.locationID = ""
+	if len(session.UnlockedLocationIDs) > 0 {
+		g.locationID = session.UnlockedLocationIDs[0]
+	}
+
+	fmt.Printf("\n== %s ==\n\n%s\n%s\n\n", public.Story.Title, public.Story.NewsArticle.Title, public.Story.NewsArticle.Content)
+	fmt.Printf("Session %s\n\n", session.ID)
+	g.look()
+	return nil
+}
+
+// refreshSession reloads progress after actions that can reveal things
+func (g *game) refreshSession() error {
+	session, err := g.client.Session(g.session.ID)
+	if err != nil {
+		return err
+	}
+	g.session = session
+	return nil
+}
+
+func (g *game) status() error {
+	if err := g.refreshSession(); err != nil {
+		return err
+	}
+	s := g.session
+	fmt.Printf("Story:      %s\n", g.story.title)
+	fmt.Printf("Location:   %s\n", g.locationID)
+	fmt.Printf("Messages:   %d\n", s.MessageCount)
+	fmt.Printf("Locations:  %s\n", joinOrNone(s.UnlockedLocationIDs))
+	fmt.Printf("Evidence:   %s\n", joinOrNone(s.DiscoveredEvidenceIDs))
+	fmt.Printf("Containers: %s\n", joinOrNone(s.OpenedContainerIDs))
+	return nil
+}
+
+func (g *game) look() {
+	location, known := g.location(g.locationID)
+	if !known {
+		fmt.Printf("You are at %s.\n", g.locationID)
+		fmt.Println("Characters in this case:")
+		for _, c := range g.story.characterOrder {
+			fmt.Printf("  %s  %s\n", c, g.story.characterName(c))
+		}
+		return
+	}
+
+	fmt.Printf("You are at %s.\n%s\n", location.LocationName, location.VisualDescription)
+	if len(location.CharacterIDsInLocation) > 0 {
+		fmt.Println("Characters here:")
+		for _, id := range location.CharacterIDsInLocation {
+			fmt.Printf("  %s  %s\n", id, g.story.characterName(id))
+		}
+	}
+	if len(location.Containers) > 0 {
+		fmt.Println("Containers:")
+		for _, c := range location.Containers {
+			lock := "open"
+			if c.IsLocked {
+				lock = "locked"
+			}
+			fmt.Printf("  %s  %s (%s, %s)\n", c.ID, c.Name, c.Type, lock)
+		}
+	}
+}
+
+// location describes an unlocked location. The public story only has the
+// starting locations, so the others are fetched from the session and cached.
+func (g *game) location(id string) (models.PublicLocation, bool) {
+	if l, ok := g.story.locations[id]; ok {
+		return l, true
+	}
+	l, err := g.client.Location(g.session.ID, id)
+	if err != nil {
+		fmt.Printf("Could not describe %s: %v\n", id, err)
+		return models.PublicLocation{}, false
+	}
+	g.story.locations[id] = *l
+	return *l, true
+}
+
+func (g *game) locations() error {
+	if err := g.refreshSession(); err != nil {
+		return err
+	}
+	for _, id := range g.session.UnlockedLocationIDs {
+		marker := " "
+		if id == g.locationID {
+			marker = "*"
+		}
+		name := id
+		if l, ok := g.location(id); ok {
+			name = l.LocationName
+		}
+		fmt.Printf(" %s %s  %s\n", marker, id, name)
+	}
+	return nil
+}
+
+func (g *game) goTo(locationID string) error {
+	if err := g.refreshSession(); err != nil {
+		return err
+	}
+	if !slices.Contains(g.session.UnlockedLocationIDs, locationID) {
+		return fmt.Errorf("you don't know how to get to %q yet", locationID)
+	}
+	g.locationID = locationID
+	g.talkingTo = ""
+	g.look()
+	return nil
+}
+
+func (g *game) talk(characterID string) error {
+	if characterID == "" {
+		return fmt.Errorf("usage: talk <character_id>")
+	}
+	if _, ok := g.characters[characterID]; !ok {
+		agentID, err := g.client.Spawn(handlers.SpawnRequest{
+			StoryID:     g.session.StoryID,
+			CharacterID: characterID,
+			SessionID:   g.session.ID,
+		})
+		if err != nil {
+			return err
+		}
+		g.characters[characterID] = agentID
+	}
+	g.talkingTo = characterID
+	fmt.Printf("You approach %s.\n", g.story.characterName(characterID))
+	return nil
+}
+
+func (g *game) say(message string, evidenceIDs []string) error {
+	if g.talkingTo == "" {
+		return fmt.Errorf("you are not talking to anyone, use \"talk\" first")
+	}
+	if message == "" {
+		return fmt.Errorf("say what?")
+	}
+
+	resp, err := g.client.Message(handlers.MessageRequest{
+		AgentID:              g.characters[g.talkingTo],
+		Message:              message,
+		PresentedEvidenceIDs: evidenceIDs,
+		LocationID:           g.locationID,
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%s: %s\n", g.story.characterName(g.talkingTo), resp.Reply)
+	if len(resp.RevealedEvidences) > 0 {
+		fmt.Printf("  + Evidence: %s\n", strings.Join(resp.RevealedEvidences, ", "))
+	}
+	if len(resp.RevealedLocations) > 0 {
+		fmt.Printf("  + Locations: %s\n", strings.Join(resp.RevealedLocations, ", "))
+	}
+	fmt.Printf("  (reputation %d, intimidation %d)\n", resp.Standing.Reputation, resp.Standing.Intimidation)
+	return nil
+}
+
+func (g *game) evidence() error {
+	if err := g.refreshSession(); err != nil {
+		return err
+	}
+	fmt.Println(joinOrNone(g.session.DiscoveredEvidenceIDs))
+	return nil
+}
+
+func (g *game) open(containerID, code string) error {
+	if containerID == "" {
+		return fmt.Errorf("usage: open <container_id> <code>")
+	}
+
+	resp, err := g.client.UnlockContainer(handlers.ContainerUnlockRequest{
+		SessionID:   g.session.ID,
+		ContainerID: containerID,
+		Code:        code,
+	})
+	if err != nil {
+		return err
+	}
+
+	if !resp.Unlocked {
+		fmt.Printf("It doesn't open (attempt %d).\n", resp.Attempts)
+		if resp.CodeHint != nil {
+			fmt.Printf("Hint: %s\n", resp.CodeHint.Description)
+		}
+		return nil
+	}
+
+	fmt.Println("It opens. Inside you find:")
+	for _, e := range resp.Evidence {
+		fmt.Printf("  %s  %s: %s\n", e.ID, e.Title, e.Description)
+	}
+	return nil
+}
+
+func (g *game) theory(text string) error {
+	if text == "" {
+		return fmt.Errorf("usage: theory <text>")
+	}
+
+	resp, err := g.client.Score(handlers.ScoreRequest{
+		SessionID:  g.session.ID,
+		Theory:     text,
+		Accusation: g.accusation,
+	})
+	if err != nil {
+		return err
+	}
+
+	b := resp.Breakdown
+	fmt.Printf("\nScore: %d/100\n", resp.Score)
+	fmt.Printf("  Culprit %d/30, motive %d/20, sequence %d/20, evidence %d/20, relationships %d/10\n",
+		b.Culprit, b.Motive, b.Sequence, b.Evidence, b.Relationships)
+	fmt.Printf("  Used well: %s\n  Missed:    %s\n", joinOrNone(resp.EvidenceUsedWell), joinOrNone(resp.EvidenceMissed))
+	fmt.Printf("\n%s\n", resp.Reason)
+	return nil
+}
+
+func splitIDs(s string) []string {
+	var ids []string
+	for _, id := range strings.Split(s, ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+func joinOrNone(ids []string) string {
+	if len(ids) == 0 {
+		return "(none)"
+	}
+	return strings.Join(ids, ", ")
+}
@@ -0,0 +1,35 @@
+package main
+
+import "agent/models"
+
+// storyView indexes the public story for quick lookups in the REPL
+type storyView struct {
+	title          string
+	characters     map[string]models.PublicCharacter
+	characterOrder []string
+	locations      map[string]models.PublicLocation
+}
+
+func newStoryView(story *models.PublicStory) *storyView {
+	v := &storyView{
+		title:      story.Story.Title,
+		characters: map[string]models.PublicCharacter{},
+		locations:  map[string]models.PublicLocation{},
+	}
+	for _, c := range story.Story.Characters {
+		v.characters[c.ID] = c
+		v.characterOrder = append(v.characterOrder, c.ID)
+	}
+	for _, l := range story.Story.Locations {
+		v.locations[l.ID] = l
+	}
+	return v
+}
+
+// characterName returns the character's name, or its ID if the story doesn't list it
+func (v *storyView) characterName(id string) string {
+	if c, ok := v.characters[id]; ok && c.Name != "" {
+		return c.Name
+	}
+	return id
+}
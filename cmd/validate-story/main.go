@@ -0,0 +1,78 @@
+// Command validate-story checks a story JSON file for broken references and
+// unreachable content. It reads the file named on the command line, or stdin,
+// and exits with status 1 if the story has errors.
+//
+// Usage:
+//
+//	go run ./cmd/validate-story story.json
+//	curl -H "Authorization: Bearer $AUTHOR_API_KEY" localhost:8080/author/stories/ID | go run ./cmd/validate-story
+package main
+
+import (
+	"agent/models"
+	"agent/validator"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+)
+
+func main() {
+	asJSON := flag.Bool("json", false, "print the full report as JSON")
+	flag.Parse()
+
+	var input io.Reader = os.Stdin
+	if flag.NArg() > 0 {
+		f, err := os.Open(flag.Arg(0))
+		if err != nil {
+			log.Fatalf("Failed to open story: %v", err)
+		}
+		defer f.Close()
+		input = f
+	}
+
+	story, err := decodeStory(input)
+	if err != nil {
+		log.Fatalf("Failed to decode story: %v", err)
+	}
+
+	report := validator.Validate(story)
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(report)
+	} else {
+		for _, issue := range report.Issues {
+			fmt.Printf("%-7s %-22s %s\n", issue.Severity, issue.Code, issue.Message)
+		}
+		fmt.Printf("\n%d issues, %d errors. Reachable: %d locations, %d characters, %d evidence\n",
+			len(report.Issues), len(report.Errors()),
+			len(report.ReachableLocations), len(report.ReachableCharacters), len(report.ReachableEvidence))
+	}
+
+	if !report.Valid {
+		os.Exit(1)
+	}
+}
+
+// decodeStory accepts either a full story document ({"story": {...}}) or just its content
+func decodeStory(r io.Reader) (*models.Story, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var story models.Story
+	if err := json.Unmarshal(data, &story); err != nil {
+		return nil, err
+	}
+	if len(story.Story.Characters) == 0 && len(story.Story.Locations) == 0 {
+		if err := json.Unmarshal(data, &story.Story); err != nil {
+			return nil, err
+		}
+	}
+	return &story, nil
+}
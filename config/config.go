@@ -2,6 +2,9 @@ package config
 
 import (
 	"os"
+	"strconv"
+	"strings"
+	"time"
 )
 
 // GetGeminiModel returns the Gemini model to use from environment variable
@@ -29,3 +32,312 @@ func GetMongoDBURI() string {
 func GetAllowedOrigins() string {
 	return os.Getenv("ALLOWED_ORIGINS")
 }
+
+// GetStoryCollections returns the story collections clients may read from, keyed by alias
+// STORY_COLLECTIONS is a comma-separated list of "alias=collection" or bare collection names,
+// e.g. "stories,drafts=story_drafts,spring=seasonal_spring"
+// Defaults to the "stories" collection only if not set
+func GetStoryCollections() map[string]string {
+	collections := map[string]string{}
+	for _, entry := range strings.Split(os.Getenv("STORY_COLLECTIONS"), ",") {
+		alias, name, found := strings.Cut(entry, "=")
+		alias = strings.TrimSpace(alias)
+		name = strings.TrimSpace(name)
+		if !found {
+			name = alias
+		}
+		if alias == "" || name == "" {
+			continue
+		}
+		collections[alias] = name
+	}
+	if len(collections) == 0 {
+		collections["stories"] = "stories"
+	}
+	return collections
+}
+
+// GetAuthorAPIKey returns the key story authors use to access full story documents
+// The author endpoints reject every request if it is not set
+func GetAuthorAPIKey() string {
+	return os.Getenv("AUTHOR_API_KEY")
+}
+
+// GetAuthHMACSecret returns the shared secret player tokens are signed with
+// Guest tokens can only be issued when it is set
+func GetAuthHMACSecret() string {
+	return os.Getenv("AUTH_HMAC_SECRET")
+}
+
+// GetAuthJWKSFile returns the path of a JWKS file with the public keys player tokens may be signed with
+func GetAuthJWKSFile() string {
+	return os.Getenv("AUTH_JWKS_FILE")
+}
+
+// GetAuthIssuer returns the issuer player tokens must carry and guest tokens are issued with
+// Any issuer is accepted if not set
+func GetAuthIssuer() string {
+	return os.Getenv("AUTH_ISSUER")
+}
+
+// GetGuestTokenTTL returns how long guest tokens stay valid
+// Defaults to 30 days if not set or invalid
+func GetGuestTokenTTL() time.Duration {
+	ttl, err := time.ParseDuration(os.Getenv("AUTH_GUEST_TOKEN_TTL"))
+	if err != nil || ttl <= 0 {
+		return 30 * 24 * time.Hour
+	}
+	return ttl
+}
+
+// GetRateLimitBackend returns where rate limit buckets are kept ("memory" or "mongo")
+// Defaults to "memory" if not set; use "mongo" when running more than one instance
+func GetRateLimitBackend() string {
+	backend := os.Getenv("RATE_LIMIT_BACKEND")
+	if backend == "" {
+		return "memory"
+	}
+	return backend
+}
+
+// GetRateLimit returns the budget for a route from RATE_LIMIT_<ROUTE>, e.g.
+// RATE_LIMIT_SCORE="5/m:3" or "off"
+// Defaults to def if not set
+func GetRateLimit(route, def string) string {
+	budget := os.Getenv("RATE_LIMIT_" + strings.ToUpper(route))
+	if budget == "" {
+		return def
+	}
+	return budget
+}
+
+// GetTrustProxyHeaders reports whether the client IP is taken from X-Forwarded-For,
+// which is only safe behind a proxy that sets it
+// Defaults to false if not set
+func GetTrustProxyHeaders() bool {
+	return os.Getenv("TRUST_PROXY_HEADERS") == "true"
+}
+
+// GetTrustedProxyHops returns how many trusted proxies append to X-Forwarded-For
+// in front of the server; the client IP is the entry the outermost one added
+// Defaults to 1 if not set or invalid
+func GetTrustedProxyHops() int {
+	hops, err := strconv.Atoi(os.Getenv("TRUSTED_PROXY_HOPS"))
+	if err != nil || hops <= 0 {
+		return 1
+	}
+	return hops
+}
+
+// GetLLMProvider returns the LLM provider to use ("gemini", "openai" or "fake")
+// Defaults to "gemini" if not set
+func GetLLMProvider() string {
+	provider := os.Getenv("LLM_PROVIDER")
+	if provider == "" {
+		return "gemini"
+	}
+	return provider
+}
+
+// GetOpenAIBaseURL returns the base URL of an OpenAI-compatible server
+// Defaults to a local server at "http://localhost:11434/v1" if not set
+func GetOpenAIBaseURL() string {
+	baseURL := os.Getenv("OPENAI_BASE_URL")
+	if baseURL == "" {
+		return "http://localhost:11434/v1"
+	}
+	return baseURL
+}
+
+// GetOpenAIAPIKey returns the API key for the OpenAI-compatible server, if any
+func GetOpenAIAPIKey() string {
+	return os.Getenv("OPENAI_API_KEY")
+}
+
+// GetOpenAIModel returns the model name to request from the OpenAI-compatible server
+func GetOpenAIModel() string {
+	return os.Getenv("OPENAI_MODEL")
+}
+
+// GetAgentRegistryMaxSize returns the maximum number of agents kept in memory
+// Defaults to 1000 if not set or invalid
+func GetAgentRegistryMaxSize() int {
+	size, err := strconv.Atoi(os.Getenv("AGENT_REGISTRY_MAX_SIZE"))
+	if err != nil || size < 0 {
+		return 1000
+	}
+	return size
+}
+
+// GetAgentRegistryIdleTTL returns how long an agent may sit idle before eviction
+// Defaults to 2 hours if not set or invalid
+func GetAgentRegistryIdleTTL() time.Duration {
+	ttl, err := time.ParseDuration(os.Getenv("AGENT_REGISTRY_IDLE_TTL"))
+	if err != nil || ttl < 0 {
+		return 2 * time.Hour
+	}
+	return ttl
+}
+
+// GetAgentPreloadWindow returns how far back to look for active agents on startup
+// Defaults to 24 hours if not set or invalid
+func GetAgentPreloadWindow() time.Duration {
+	window, err := time.ParseDuration(os.Getenv("AGENT_PRELOAD_WINDOW"))
+	if err != nil || window <= 0 {
+		return 24 * time.Hour
+	}
+	return window
+}
+
+// GetAgentPreloadLimit returns the maximum number of agents to warm up on startup
+// Defaults to 50 if not set or invalid; 0 disables the warm-up
+func GetAgentPreloadLimit() int {
+	limit, err := strconv.Atoi(os.Getenv("AGENT_PRELOAD_LIMIT"))
+	if err != nil || limit < 0 {
+		return 50
+	}
+	return limit
+}
+
+// GetAgentPreloadWorkers returns how many agents are loaded concurrently during warm-up
+// Defaults to 8 if not set or invalid
+func GetAgentPreloadWorkers() int {
+	workers, err := strconv.Atoi(os.Getenv("AGENT_PRELOAD_WORKERS"))
+	if err != nil || workers <= 0 {
+		return 8
+	}
+	return workers
+}
+
+// GetContainerMaxAttempts returns how many wrong codes a session may try on a
+// container before it is locked out
+// Defaults to 5 if not set or invalid; 0 disables the lockout
+func GetContainerMaxAttempts() int {
+	attempts, err := strconv.Atoi(os.Getenv("CONTAINER_MAX_ATTEMPTS"))
+	if err != nil || attempts < 0 {
+		return 5
+	}
+	return attempts
+}
+
+// GetContainerLockout returns how long a container stays locked out after too many wrong codes
+// Defaults to 5 minutes if not set or invalid
+func GetContainerLockout() time.Duration {
+	lockout, err := time.ParseDuration(os.Getenv("CONTAINER_LOCKOUT"))
+	if err != nil || lockout <= 0 {
+		return 5 * time.Minute
+	}
+	return lockout
+}
+
+// GetHistoryTokenBudget returns the estimated token count the conversation after the
+// system prompt may reach before older turns are compacted into a summary
+// Defaults to 6000 if not set or invalid; 0 disables compaction
+func GetHistoryTokenBudget() int {
+	budget, err := strconv.Atoi(os.Getenv("AGENT_HISTORY_TOKEN_BUDGET"))
+	if err != nil || budget < 0 {
+		return 6000
+	}
+	return budget
+}
+
+// GetHistoryKeepTurns returns how many of the most recent turns compaction keeps verbatim
+// Defaults to 4 if not set or invalid
+func GetHistoryKeepTurns() int {
+	turns, err := strconv.Atoi(os.Getenv("AGENT_HISTORY_KEEP_TURNS"))
+	if err != nil || turns < 1 {
+		return 4
+	}
+	return turns
+}
+
+// GetScoreEnsembleMaxJudges returns the most judgments an ensemble /score request may ask for
+// Defaults to 5 if not set or invalid
+func GetScoreEnsembleMaxJudges() int {
+	judges, err := strconv.Atoi(os.Getenv("SCORE_ENSEMBLE_MAX_JUDGES"))
+	if err != nil || judges < 2 {
+		return 5
+	}
+	return judges
+}
+
+// GetScoreEnsembleTimeout returns how long ensemble judgments may run before the
+// stragglers are dropped
+// Defaults to 20 seconds if not set or invalid
+func GetScoreEnsembleTimeout() time.Duration {
+	timeout, err := time.ParseDuration(os.Getenv("SCORE_ENSEMBLE_TIMEOUT"))
+	if err != nil || timeout <= 0 {
+		return 20 * time.Second
+	}
+	return timeout
+}
+
+// GetServerAddr returns the address the HTTP server listens on
+// Defaults to ":8080" if not set
+func GetServerAddr() string {
+	addr := os.Getenv("SERVER_ADDR")
+	if addr == "" {
+		return ":8080"
+	}
+	return addr
+}
+
+// GetServerReadTimeout returns how long the server waits to read a full request
+// Defaults to 15 seconds if not set or invalid
+func GetServerReadTimeout() time.Duration {
+	timeout, err := time.ParseDuration(os.Getenv("SERVER_READ_TIMEOUT"))
+	if err != nil || timeout <= 0 {
+		return 15 * time.Second
+	}
+	return timeout
+}
+
+// GetServerWriteTimeout returns how long a handler may take to write its response.
+// It must outlast the 60 second LLM calls behind /message and /score.
+// Defaults to 90 seconds if not set or invalid
+func GetServerWriteTimeout() time.Duration {
+	timeout, err := time.ParseDuration(os.Getenv("SERVER_WRITE_TIMEOUT"))
+	if err != nil || timeout <= 0 {
+		return 90 * time.Second
+	}
+	return timeout
+}
+
+// GetServerIdleTimeout returns how long an idle keep-alive connection stays open
+// Defaults to 120 seconds if not set or invalid
+func GetServerIdleTimeout() time.Duration {
+	timeout, err := time.ParseDuration(os.Getenv("SERVER_IDLE_TIMEOUT"))
+	if err != nil || timeout <= 0 {
+		return 120 * time.Second
+	}
+	return timeout
+}
+
+// GetShutdownTimeout returns how long shutdown waits for in-flight requests and
+// background LLM calls before giving up. Like the write timeout, it must outlast
+// the 60 second LLM calls, or shutdown gives up on requests still mid-call.
+// Defaults to 90 seconds if not set or invalid
+func GetShutdownTimeout() time.Duration {
+	timeout, err := time.ParseDuration(os.Getenv("SHUTDOWN_TIMEOUT"))
+	if err != nil || timeout <= 0 {
+		return 90 * time.Second
+	}
+	return timeout
+}
+
+// GetReadyCheckLLM reports whether /readyz also checks that the LLM provider is reachable
+// Defaults to false if not set
+func GetReadyCheckLLM() bool {
+	return os.Getenv("READY_CHECK_LLM") == "true"
+}
+
+// GetReadyLLMCacheTTL returns how long /readyz reuses the result of the LLM check,
+// so frequent probes don't hit the provider on every request
+// Defaults to 1 minute if not set or invalid
+func GetReadyLLMCacheTTL() time.Duration {
+	ttl, err := time.ParseDuration(os.Getenv("READY_LLM_CACHE_TTL"))
+	if err != nil || ttl < 0 {
+		return time.Minute
+	}
+	return ttl
+}
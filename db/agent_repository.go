@@ -3,6 +3,7 @@ package db
 import (
 	"agent/db/models"
 	"context"
+	"errors"
 	"log"
 	"strings"
 	"time"
@@ -13,6 +14,12 @@ import (
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+// ErrDuplicateIndex is returned when a conversation message reuses an index already stored for its agent
+var ErrDuplicateIndex = errors.New("conversation index already in use")
+
+// ErrAgentExists is returned when the session already has an agent for the character
+var ErrAgentExists = errors.New("session already has an agent for this character")
+
 // CreateAgent inserts a new agent and returns its ID
 func CreateAgent(ctx context.Context, agent *models.AgentDocument) (primitive.ObjectID, error) {
 	agent.CreatedAt = time.Now()
@@ -20,6 +27,9 @@ func CreateAgent(ctx context.Context, agent *models.AgentDocument) (primitive.Ob
 
 	collection := GetCollection("agents")
 	result, err := collection.InsertOne(ctx, agent)
+	if mongo.IsDuplicateKeyError(err) {
+		return primitive.NilObjectID, ErrAgentExists
+	}
 	if err != nil {
 		return primitive.NilObjectID, err
 	}
@@ -27,6 +37,75 @@ func CreateAgent(ctx context.Context, agent *models.AgentDocument) (primitive.Ob
 	return result.InsertedID.(primitive.ObjectID), nil
 }
 
+// FindSessionAgent returns the ID of the session's agent for a character
+func FindSessionAgent(ctx context.Context, sessionID, characterID string) (string, error) {
+	var agent models.AgentDocument
+	filter := bson.M{"session_id": sessionID, "character_id": characterID}
+	opts := options.FindOne().SetProjection(bson.M{"_id": 1})
+	if err := GetCollection("agents").FindOne(ctx, filter, opts).Decode(&agent); err != nil {
+		return "", err
+	}
+	return agent.ID.Hex(), nil
+}
+
+// GetAgentOwner returns the ID of the player who spawned the agent, empty for
+// guest agents. Only the owner is read, so the agent itself isn't loaded.
+func GetAgentOwner(ctx context.Context, agentID string) (string, error) {
+	objID, err := primitive.ObjectIDFromHex(agentID)
+	if err != nil {
+		return "", err
+	}
+
+	var agent models.AgentDocument
+	opts := options.FindOne().SetProjection(bson.M{"player_id": 1})
+	if err := GetCollection("agents").FindOne(ctx, bson.M{"_id": objID}, opts).Decode(&agent); err != nil {
+		return "", err
+	}
+	return agent.PlayerID, nil
+}
+
+// UpdateAgentReveals persists the agent's revealed evidence and location maps
+func UpdateAgentReveals(ctx context.Context, agentID string, revealedEvidenceIDs map[string]bool, revealedLocationIDs map[string]bool) error {
+	objID, err := primitive.ObjectIDFromHex(agentID)
+	if err != nil {
+		return err
+	}
+
+	update := bson.M{
+		"$set": bson.M{
+			"revealed_evidence_ids": revealedEvidenceIDs,
+			"revealed_location_ids": revealedLocationIDs,
+			"updated_at":            time.Now(),
+		},
+	}
+
+	collection := GetCollection("agents")
+	_, err = collection.UpdateOne(ctx, bson.M{"_id": objID}, update)
+	return err
+}
+
+// UpdateAgentStanding persists the agent's reputation, intimidation and the
+// evidence already presented to it
+func UpdateAgentStanding(ctx context.Context, agentID string, reputation int, intimidation int, presentedEvidenceIDs map[string]bool) error {
+	objID, err := primitive.ObjectIDFromHex(agentID)
+	if err != nil {
+		return err
+	}
+
+	update := bson.M{
+		"$set": bson.M{
+			"reputation":             reputation,
+			"intimidation":           intimidation,
+			"presented_evidence_ids": presentedEvidenceIDs,
+			"updated_at":             time.Now(),
+		},
+	}
+
+	collection := GetCollection("agents")
+	_, err = collection.UpdateOne(ctx, bson.M{"_id": objID}, update)
+	return err
+}
+
 // SaveConversationMessage saves a single message - wrapper for backward compatibility
 func SaveConversationMessage(ctx context.Context, agentID string, content string, role string, index int) error {
 	// For backward compatibility, use same content for both versions
@@ -67,6 +146,13 @@ func SaveConversationMessageWithVersions(ctx context.Context, agentID string, fu
 		if err == nil {
 			return nil
 		}
+		if mongo.IsDuplicateKeyError(err) {
+			// A retry can collide with an earlier attempt that did land
+			if i > 0 && conversationMessageStored(ctx, collection, doc) {
+				return nil
+			}
+			return ErrDuplicateIndex
+		}
 		lastErr = err
 		time.Sleep(time.Millisecond * 100 * time.Duration(i+1)) // Exponential backoff
 	}
@@ -74,6 +160,26 @@ func SaveConversationMessageWithVersions(ctx context.Context, agentID string, fu
 	return lastErr
 }
 
+// DeleteConversationMessage removes the message stored at index for the agent
+func DeleteConversationMessage(ctx context.Context, agentID string, index int) error {
+	objID, err := primitive.ObjectIDFromHex(agentID)
+	if err != nil {
+		return err
+	}
+	_, err = GetCollection("conversations").DeleteOne(ctx, bson.M{"agent_id": objID, "index": index})
+	return err
+}
+
+// conversationMessageStored reports whether doc is already stored at its index
+func conversationMessageStored(ctx context.Context, collection *mongo.Collection, doc models.ConversationDocument) bool {
+	var existing models.ConversationDocument
+	filter := bson.M{"agent_id": doc.AgentID, "index": doc.Index}
+	if err := collection.FindOne(ctx, filter).Decode(&existing); err != nil {
+		return false
+	}
+	return existing.Role == doc.Role && existing.Content == doc.Content
+}
+
 // GetConversationHistory retrieves paginated conversation history
 func GetConversationHistory(ctx context.Context, agentID string, limit, offset int) ([]models.ConversationDocument, int64, error) {
 	objID, err := primitive.ObjectIDFromHex(agentID)
@@ -91,7 +197,7 @@ func GetConversationHistory(ctx context.Context, agentID string, limit, offset i
 
 	// Fetch paginated messages
 	opts := options.Find().
-		SetSort(bson.D{{"index", 1}}).
+		SetSort(bson.D{{Key: "index", Value: 1}}).
 		SetLimit(int64(limit)).
 		SetSkip(int64(offset))
 
@@ -109,32 +215,205 @@ func GetConversationHistory(ctx context.Context, agentID string, limit, offset i
 	return messages, total, nil
 }
 
+// SaveConversationSummary stores a new rolling summary for an agent
+func SaveConversationSummary(ctx context.Context, agentID string, summary string, throughIndex int) error {
+	objID, err := primitive.ObjectIDFromHex(agentID)
+	if err != nil {
+		return err
+	}
+
+	doc := models.ConversationSummaryDocument{
+		AgentID:      objID,
+		Summary:      summary,
+		ThroughIndex: throughIndex,
+		CreatedAt:    time.Now(),
+	}
+
+	collection := GetCollection("conversation_summaries")
+	_, err = collection.InsertOne(ctx, doc)
+	return err
+}
+
+// GetLatestConversationSummary returns the agent's most recent summary, or nil if
+// its history has never been compacted
+func GetLatestConversationSummary(ctx context.Context, agentID primitive.ObjectID) (*models.ConversationSummaryDocument, error) {
+	opts := options.FindOne().SetSort(bson.D{{Key: "through_index", Value: -1}})
+
+	var summary models.ConversationSummaryDocument
+	collection := GetCollection("conversation_summaries")
+	err := collection.FindOne(ctx, bson.M{"agent_id": agentID}, opts).Decode(&summary)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &summary, nil
+}
+
 // CreateIndexes creates necessary indexes for performance
 func CreateAgentIndexes() {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	// Create index for conversations collection
-	conversationIndexes := []mongo.IndexModel{
-		{
-			Keys: bson.D{
-				{"agent_id", 1},
-				{"index", 1},
-			},
-			Options: options.Index().SetBackground(true),
+	collection := GetCollection("conversations")
+	timestampIndex := mongo.IndexModel{
+		Keys: bson.D{
+			{Key: "agent_id", Value: 1},
+			{Key: "timestamp", Value: -1},
 		},
-		{
-			Keys: bson.D{
-				{"agent_id", 1},
-				{"timestamp", -1},
-			},
-			Options: options.Index().SetBackground(true),
+		Options: options.Index().SetBackground(true),
+	}
+	if _, err := collection.Indexes().CreateOne(ctx, timestampIndex); err != nil {
+		log.Printf("Failed to create indexes: %v", err)
+	}
+	ensureUniqueConversationIndex(collection)
+
+	// One agent per character in a session; agents spawned without a session have no session_id
+	agentIndex := mongo.IndexModel{
+		Keys: bson.D{
+			{Key: "session_id", Value: 1},
+			{Key: "character_id", Value: 1},
 		},
+		Options: options.Index().
+			SetUnique(true).
+			SetPartialFilterExpression(bson.M{"session_id": bson.M{"$type": "string"}}).
+			SetBackground(true),
+	}
+	if _, err := GetCollection("agents").Indexes().CreateOne(ctx, agentIndex); err != nil {
+		log.Printf("Failed to create agent indexes: %v", err)
 	}
 
-	collection := GetCollection("conversations")
-	_, err := collection.Indexes().CreateMany(ctx, conversationIndexes)
+	summaryIndex := mongo.IndexModel{
+		Keys: bson.D{
+			{Key: "agent_id", Value: 1},
+			{Key: "through_index", Value: -1},
+		},
+		Options: options.Index().SetBackground(true),
+	}
+	if _, err := GetCollection("conversation_summaries").Indexes().CreateOne(ctx, summaryIndex); err != nil {
+		log.Printf("Failed to create conversation summary index: %v", err)
+	}
+}
+
+// conversationIndexName is the (agent_id, index) index on conversations. Early
+// deployments created it without the unique option.
+const conversationIndexName = "agent_id_1_index_1"
+
+// ensureUniqueConversationIndex makes the (agent_id, index) index unique, so a
+// second message claiming a slot already in use is rejected. Messages stored
+// under an index that is already taken are moved to conversation_duplicates
+// first, as the unique build would fail on them. The old index is only dropped
+// right before the unique one is built, and is put back if that build fails.
+func ensureUniqueConversationIndex(collection *mongo.Collection) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	specs, err := collection.Indexes().ListSpecifications(ctx)
 	if err != nil {
-		log.Printf("Failed to create indexes: %v", err)
+		log.Printf("Failed to list indexes on %s: %v", collection.Name(), err)
+		return
+	}
+	exists := false
+	for _, spec := range specs {
+		if spec.Name != conversationIndexName {
+			continue
+		}
+		if spec.Unique != nil && *spec.Unique {
+			return
+		}
+		exists = true
+	}
+
+	moved, err := moveDuplicateConversationMessages(ctx, collection)
+	if err != nil {
+		log.Printf("Failed to move duplicate conversation messages, keeping the non-unique index: %v", err)
+		if !exists {
+			createConversationIndex(ctx, collection, false)
+		}
+		return
+	}
+	if moved > 0 {
+		log.Printf("[INDEX_MIGRATION] Moved %d duplicate conversation messages to conversation_duplicates", moved)
+	}
+
+	if exists {
+		if _, err := collection.Indexes().DropOne(ctx, conversationIndexName); err != nil {
+			log.Printf("Failed to drop non-unique index %s: %v", conversationIndexName, err)
+			return
+		}
+	}
+	if err := createConversationIndex(ctx, collection, true); err != nil {
+		log.Printf("Failed to create unique index %s, restoring the non-unique one: %v", conversationIndexName, err)
+		createConversationIndex(ctx, collection, false)
+	}
+}
+
+// createConversationIndex builds the (agent_id, index) index on conversations
+func createConversationIndex(ctx context.Context, collection *mongo.Collection, unique bool) error {
+	index := mongo.IndexModel{
+		Keys: bson.D{
+			{Key: "agent_id", Value: 1},
+			{Key: "index", Value: 1},
+		},
+		Options: options.Index().SetName(conversationIndexName).SetUnique(unique).SetBackground(true),
+	}
+	_, err := collection.Indexes().CreateOne(ctx, index)
+	if err != nil && !unique {
+		log.Printf("Failed to create index %s: %v", conversationIndexName, err)
+	}
+	return err
+}
+
+// moveDuplicateConversationMessages keeps the first stored message for every
+// (agent_id, index) pair and moves the others to conversation_duplicates.
+// It returns how many messages were moved.
+func moveDuplicateConversationMessages(ctx context.Context, collection *mongo.Collection) (int, error) {
+	pipeline := []bson.M{
+		{"$sort": bson.M{"_id": 1}},
+		{"$group": bson.M{
+			"_id":   bson.M{"agent_id": "$agent_id", "index": "$index"},
+			"ids":   bson.M{"$push": "$_id"},
+			"count": bson.M{"$sum": 1},
+		}},
+		{"$match": bson.M{"count": bson.M{"$gt": 1}}},
+	}
+	cursor, err := collection.Aggregate(ctx, pipeline, options.Aggregate().SetAllowDiskUse(true))
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	duplicates := GetCollection("conversation_duplicates")
+	moved := 0
+	for cursor.Next(ctx) {
+		var group struct {
+			IDs []primitive.ObjectID `bson:"ids"`
+		}
+		if err := cursor.Decode(&group); err != nil {
+			return moved, err
+		}
+
+		extra := bson.M{"_id": bson.M{"$in": group.IDs[1:]}}
+		var docs []bson.M
+		found, err := collection.Find(ctx, extra)
+		if err != nil {
+			return moved, err
+		}
+		if err := found.All(ctx, &docs); err != nil {
+			return moved, err
+		}
+		for _, doc := range docs {
+			// A copy left by an earlier, interrupted run is already there
+			if _, err := duplicates.InsertOne(ctx, doc); err != nil && !mongo.IsDuplicateKeyError(err) {
+				return moved, err
+			}
+		}
+		result, err := collection.DeleteMany(ctx, extra)
+		if err != nil {
+			return moved, err
+		}
+		moved += int(result.DeletedCount)
 	}
+	return moved, cursor.Err()
 }
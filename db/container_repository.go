@@ -0,0 +1,124 @@
+package db
+
+import (
+	"agent/db/models"
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// GetContainerAttempt returns the attempt record for a session and container, or nil if none exists
+func GetContainerAttempt(ctx context.Context, sessionID string, storyID primitive.ObjectID, containerID string) (*models.ContainerAttemptDocument, error) {
+	filter := bson.M{
+		"session_id":   sessionID,
+		"story_id":     storyID,
+		"container_id": containerID,
+	}
+
+	var doc models.ContainerAttemptDocument
+	err := GetCollection("container_attempts").FindOne(ctx, filter).Decode(&doc)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &doc, nil
+}
+
+// ErrContainerLocked is returned when a session is locked out of a container after too many wrong codes
+var ErrContainerLocked = errors.New("container is locked out")
+
+// RecordContainerAttempt counts an unlock attempt and marks the container as
+// unlocked on success. Every maxAttempts-th wrong code in a row locks the
+// container for lockout; a maxAttempts of 0 never locks. The lockout check and
+// the count are a single update, so concurrent guesses can't slip past it.
+// It returns the updated record, or the current one with ErrContainerLocked
+// while a lockout is running.
+func RecordContainerAttempt(ctx context.Context, sessionID string, storyID primitive.ObjectID, containerID string, unlocked bool, maxAttempts int, lockout time.Duration) (*models.ContainerAttemptDocument, error) {
+	now := time.Now()
+	key := bson.M{
+		"session_id":   sessionID,
+		"story_id":     storyID,
+		"container_id": containerID,
+	}
+	filter := bson.M{
+		"session_id":   sessionID,
+		"story_id":     storyID,
+		"container_id": containerID,
+		"locked_until": bson.M{"$not": bson.M{"$gt": now}},
+	}
+
+	count := bson.M{
+		"attempts":        bson.M{"$add": bson.A{bson.M{"$ifNull": bson.A{"$attempts", 0}}, 1}},
+		"last_attempt_at": now,
+		"created_at":      bson.M{"$ifNull": bson.A{"$created_at", now}},
+	}
+	if unlocked {
+		count["unlocked"] = true
+		count["unlocked_at"] = now
+		count["failed_streak"] = 0
+	} else {
+		count["failed_streak"] = bson.M{"$add": bson.A{bson.M{"$ifNull": bson.A{"$failed_streak", 0}}, 1}}
+	}
+	update := mongo.Pipeline{{{Key: "$set", Value: count}}}
+	if !unlocked && maxAttempts > 0 {
+		limitReached := bson.M{"$gte": bson.A{"$failed_streak", maxAttempts}}
+		update = append(update, bson.D{{Key: "$set", Value: bson.M{
+			"locked_until":  bson.M{"$cond": bson.A{limitReached, now.Add(lockout), "$locked_until"}},
+			"failed_streak": bson.M{"$cond": bson.A{limitReached, 0, "$failed_streak"}},
+		}}})
+	}
+
+	opts := options.FindOneAndUpdate().
+		SetUpsert(true).
+		SetReturnDocument(options.After)
+
+	collection := GetCollection("container_attempts")
+	for try := 0; ; try++ {
+		var doc models.ContainerAttemptDocument
+		err := collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&doc)
+		if err == nil {
+			return &doc, nil
+		}
+		if !mongo.IsDuplicateKeyError(err) || try > 0 {
+			return nil, err
+		}
+
+		// The record exists but didn't match: it is locked, or a concurrent
+		// first attempt created it, in which case the update is retried
+		if err := collection.FindOne(ctx, key).Decode(&doc); err != nil {
+			return nil, err
+		}
+		if doc.LockedUntil != nil && doc.LockedUntil.After(now) {
+			return &doc, ErrContainerLocked
+		}
+	}
+}
+
+// CreateContainerIndexes creates the indexes used for container attempt lookups
+func CreateContainerIndexes() {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	index := mongo.IndexModel{
+		Keys: bson.D{
+			{Key: "session_id", Value: 1},
+			{Key: "story_id", Value: 1},
+			{Key: "container_id", Value: 1},
+		},
+		Options: options.Index().SetUnique(true).SetBackground(true),
+	}
+
+	_, err := GetCollection("container_attempts").Indexes().CreateOne(ctx, index)
+	if err != nil {
+		log.Printf("Failed to create container indexes: %v", err)
+	}
+}
@@ -7,17 +7,33 @@ import (
 )
 
 type AgentDocument struct {
-	ID                  primitive.ObjectID `bson:"_id,omitempty"`
-	StoryID             primitive.ObjectID `bson:"story_id"`
-	CharacterID         string             `bson:"character_id"`
-	CharacterName       string             `bson:"character_name"`
-	Personality         string             `bson:"personality"`
-	HoldsEvidenceIDs    []string           `bson:"holds_evidence_ids"`
-	KnowsLocationIDs    []string           `bson:"knows_location_ids"`
-	RevealedEvidenceIDs map[string]bool    `bson:"revealed_evidence_ids"`
-	RevealedLocationIDs map[string]bool    `bson:"revealed_location_ids"`
-	CreatedAt           time.Time          `bson:"created_at"`
-	UpdatedAt           time.Time          `bson:"updated_at"`
+	ID                   primitive.ObjectID `bson:"_id,omitempty"`
+	StoryID              primitive.ObjectID `bson:"story_id"`
+	StoryCollection      string             `bson:"story_collection,omitempty"` // Collection the story lives in, "stories" if empty
+	SessionID            string             `bson:"session_id,omitempty"`
+	PlayerID             string             `bson:"player_id,omitempty"` // Owner, when the agent was spawned by an authenticated player
+	CharacterID          string             `bson:"character_id"`
+	CharacterName        string             `bson:"character_name"`
+	Personality          string             `bson:"personality"`
+	HoldsEvidenceIDs     []string           `bson:"holds_evidence_ids"`
+	KnowsLocationIDs     []string           `bson:"knows_location_ids"`
+	RevealedEvidenceIDs  map[string]bool    `bson:"revealed_evidence_ids"`
+	RevealedLocationIDs  map[string]bool    `bson:"revealed_location_ids"`
+	Reputation           int                `bson:"reputation"`
+	Intimidation         int                `bson:"intimidation"`
+	PresentedEvidenceIDs map[string]bool    `bson:"presented_evidence_ids,omitempty"`
+	CreatedAt            time.Time          `bson:"created_at"`
+	UpdatedAt            time.Time          `bson:"updated_at"`
+}
+
+// ConversationSummaryDocument is a rolling summary of an agent's older turns.
+// Each compaction stores a new one that covers everything the previous one did.
+type ConversationSummaryDocument struct {
+	ID           primitive.ObjectID `bson:"_id,omitempty"`
+	AgentID      primitive.ObjectID `bson:"agent_id"`
+	Summary      string             `bson:"summary"`
+	ThroughIndex int                `bson:"through_index"` // Last conversation index folded into the summary
+	CreatedAt    time.Time          `bson:"created_at"`
 }
 
 type ConversationDocument struct {
@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ContainerAttemptDocument tracks a player session's attempts at opening a container
+type ContainerAttemptDocument struct {
+	ID            primitive.ObjectID `bson:"_id,omitempty"`
+	SessionID     string             `bson:"session_id"`
+	StoryID       primitive.ObjectID `bson:"story_id"`
+	ContainerID   string             `bson:"container_id"`
+	Attempts      int                `bson:"attempts"`
+	Unlocked      bool               `bson:"unlocked"`
+	UnlockedAt    *time.Time         `bson:"unlocked_at,omitempty"`
+	FailedStreak  int                `bson:"failed_streak"`          // Wrong codes since the last lockout
+	LockedUntil   *time.Time         `bson:"locked_until,omitempty"` // No attempts are accepted before this
+	LastAttemptAt time.Time          `bson:"last_attempt_at"`
+	CreatedAt     time.Time          `bson:"created_at"`
+}
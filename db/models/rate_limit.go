@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// RateLimitBucketDocument is a shared token bucket for one client and route
+type RateLimitBucketDocument struct {
+	Key       string    `bson:"_id"` // "<route>|<client>"
+	Tokens    float64   `bson:"tokens"`
+	Allowed   bool      `bson:"allowed"` // Whether the last take got a token
+	UpdatedAt time.Time `bson:"updated_at"`
+	ExpiresAt time.Time `bson:"expires_at"` // When the bucket is full again and can be dropped
+}
@@ -0,0 +1,36 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ScoreSubmissionDocument records one scored theory
+type ScoreSubmissionDocument struct {
+	ID                      primitive.ObjectID `bson:"_id,omitempty"`
+	SessionID               primitive.ObjectID `bson:"session_id"`
+	PlayerID                string             `bson:"player_id"`
+	StoryID                 primitive.ObjectID `bson:"story_id"`
+	Theory                  string             `bson:"theory"`
+	Score                   int                `bson:"score"`
+	Reason                  string             `bson:"reason"`
+	CulpritCorrect          bool               `bson:"culprit_correct"`
+	Breakdown               ScoreBreakdown     `bson:"breakdown"`
+	AccusedCulpritIDs       []string           `bson:"accused_culprit_ids,omitempty"`
+	CitedEvidenceIDs        []string           `bson:"cited_evidence_ids,omitempty"`
+	DiscoveredEvidenceCount int                `bson:"discovered_evidence_count"`
+	MessageCount            int                `bson:"message_count"`
+	TimeToSolve             time.Duration      `bson:"time_to_solve"` // From session start to submission
+	Ensemble                bool               `bson:"ensemble"`
+	SubmittedAt             time.Time          `bson:"submitted_at"`
+}
+
+// ScoreBreakdown holds the points awarded for each scoring criterion
+type ScoreBreakdown struct {
+	Culprit       int `bson:"culprit"`
+	Motive        int `bson:"motive"`
+	Sequence      int `bson:"sequence"`
+	Evidence      int `bson:"evidence"`
+	Relationships int `bson:"relationships"`
+}
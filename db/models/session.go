@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// SessionDocument ties a player to a story and aggregates their investigation progress
+type SessionDocument struct {
+	ID                    primitive.ObjectID `bson:"_id,omitempty"`
+	PlayerID              string             `bson:"player_id"`
+	StoryID               primitive.ObjectID `bson:"story_id"`
+	StoryCollection       string             `bson:"story_collection,omitempty"` // Collection the story lives in, "stories" if empty
+	DiscoveredEvidenceIDs []string           `bson:"discovered_evidence_ids"`
+	UnlockedLocationIDs   []string           `bson:"unlocked_location_ids"`
+	OpenedContainerIDs    []string           `bson:"opened_container_ids"`
+	AgentIDs              map[string]string  `bson:"agent_ids"` // Character ID -> spawned agent ID
+	MessageCount          int                `bson:"message_count"`
+	CreatedAt             time.Time          `bson:"created_at"`
+	UpdatedAt             time.Time          `bson:"updated_at"`
+}
@@ -6,6 +6,9 @@ import (
 	"os"
 	"time"
 
+	"agent/metrics"
+
+	"go.mongodb.org/mongo-driver/event"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
@@ -16,6 +19,16 @@ var (
 	dataStoreDB *mongo.Database
 )
 
+// commandMonitor records the latency of every command the driver sends
+var commandMonitor = &event.CommandMonitor{
+	Succeeded: func(_ context.Context, e *event.CommandSucceededEvent) {
+		metrics.ObserveMongoCommand(e.CommandName, e.Duration, false)
+	},
+	Failed: func(_ context.Context, e *event.CommandFailedEvent) {
+		metrics.ObserveMongoCommand(e.CommandName, e.Duration, true)
+	},
+}
+
 // InitMongoDB initializes the MongoDB connection
 func InitMongoDB() error {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
@@ -26,7 +39,7 @@ func InitMongoDB() error {
 		log.Fatal("MONGODB_URI environment variable not set")
 	}
 
-	clientOptions := options.Client().ApplyURI(uri)
+	clientOptions := options.Client().ApplyURI(uri).SetMonitor(commandMonitor)
 
 	var err error
 	client, err = mongo.Connect(ctx, clientOptions)
@@ -0,0 +1,70 @@
+package db
+
+import (
+	"agent/db/models"
+	"context"
+	"log"
+	"math"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// TakeRateLimitToken refills the bucket for key at rate tokens per second up to
+// burst, then takes one token if there is one. The refill and take happen in a
+// single atomic update so concurrent server instances can't overspend. It
+// returns whether a token was taken and how many are left.
+func TakeRateLimitToken(ctx context.Context, key string, rate float64, burst int) (bool, float64, error) {
+	now := time.Now()
+
+	elapsedSeconds := bson.M{"$divide": bson.A{
+		bson.M{"$subtract": bson.A{now, bson.M{"$ifNull": bson.A{"$updated_at", now}}}},
+		1000,
+	}}
+	refilled := bson.M{"$min": bson.A{
+		burst,
+		bson.M{"$add": bson.A{
+			bson.M{"$ifNull": bson.A{"$tokens", burst}},
+			bson.M{"$multiply": bson.A{elapsedSeconds, rate}},
+		}},
+	}}
+	hasToken := bson.M{"$gte": bson.A{"$tokens", 1}}
+	fullAfter := time.Duration(math.Ceil(float64(burst) / rate * float64(time.Second)))
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{"tokens": refilled, "updated_at": now}}},
+		{{Key: "$set", Value: bson.M{
+			"allowed":    hasToken,
+			"tokens":     bson.M{"$cond": bson.A{hasToken, bson.M{"$subtract": bson.A{"$tokens", 1}}, "$tokens"}},
+			"expires_at": now.Add(fullAfter),
+		}}},
+	}
+
+	opts := options.FindOneAndUpdate().
+		SetUpsert(true).
+		SetReturnDocument(options.After)
+
+	var doc models.RateLimitBucketDocument
+	err := GetCollection("rate_limits").FindOneAndUpdate(ctx, bson.M{"_id": key}, pipeline, opts).Decode(&doc)
+	if err != nil {
+		return false, 0, err
+	}
+	return doc.Allowed, doc.Tokens, nil
+}
+
+// CreateRateLimitIndexes lets MongoDB drop buckets once they have refilled
+func CreateRateLimitIndexes() {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	index := mongo.IndexModel{
+		Keys:    bson.D{{Key: "expires_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0).SetBackground(true),
+	}
+
+	if _, err := GetCollection("rate_limits").Indexes().CreateOne(ctx, index); err != nil {
+		log.Printf("Failed to create rate limit indexes: %v", err)
+	}
+}
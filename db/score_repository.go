@@ -0,0 +1,133 @@
+package db
+
+import (
+	"agent/db/models"
+	"context"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// SaveScoreSubmission stores a scored theory and sets its ID
+func SaveScoreSubmission(ctx context.Context, submission *models.ScoreSubmissionDocument) error {
+	if submission.SubmittedAt.IsZero() {
+		submission.SubmittedAt = time.Now()
+	}
+
+	result, err := GetCollection("score_submissions").InsertOne(ctx, submission)
+	if err != nil {
+		return err
+	}
+
+	submission.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+// leaderboardOrder ranks submissions: higher score first, then fewer messages,
+// then faster solves, then earlier submissions
+var leaderboardOrder = bson.D{
+	{Key: "score", Value: -1},
+	{Key: "message_count", Value: 1},
+	{Key: "time_to_solve", Value: 1},
+	{Key: "submitted_at", Value: 1},
+}
+
+// GetStoryLeaderboard returns each player's best submission for a story, ranked.
+// Only the first submission of a session counts, so resubmitting a theory until
+// the judge scores it higher doesn't climb the leaderboard.
+func GetStoryLeaderboard(ctx context.Context, storyID primitive.ObjectID, limit int) ([]models.ScoreSubmissionDocument, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"story_id": storyID}}},
+		{{Key: "$sort", Value: bson.D{{Key: "submitted_at", Value: 1}, {Key: "_id", Value: 1}}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":   "$session_id",
+			"first": bson.M{"$first": "$$ROOT"},
+		}}},
+		{{Key: "$replaceRoot", Value: bson.M{"newRoot": "$first"}}},
+		{{Key: "$sort", Value: leaderboardOrder}},
+		// After sorting, the first submission of each player is their best
+		{{Key: "$group", Value: bson.M{
+			"_id":  "$player_id",
+			"best": bson.M{"$first": "$$ROOT"},
+		}}},
+		{{Key: "$replaceRoot", Value: bson.M{"newRoot": "$best"}}},
+		{{Key: "$sort", Value: leaderboardOrder}},
+		{{Key: "$limit", Value: limit}},
+	}
+
+	cursor, err := GetCollection("score_submissions").Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var entries []models.ScoreSubmissionDocument
+	if err := cursor.All(ctx, &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// GetPlayerSubmissions returns a player's submissions, newest first, optionally
+// limited to one story when storyID is set
+func GetPlayerSubmissions(ctx context.Context, playerID string, storyID primitive.ObjectID, limit int) ([]models.ScoreSubmissionDocument, error) {
+	filter := bson.M{"player_id": playerID}
+	if !storyID.IsZero() {
+		filter["story_id"] = storyID
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "submitted_at", Value: -1}}).
+		SetLimit(int64(limit))
+
+	cursor, err := GetCollection("score_submissions").Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var submissions []models.ScoreSubmissionDocument
+	if err := cursor.All(ctx, &submissions); err != nil {
+		return nil, err
+	}
+	return submissions, nil
+}
+
+func CreateScoreIndexes() {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	scoreIndexes := []mongo.IndexModel{
+		{
+			Keys: bson.D{
+				{Key: "story_id", Value: 1},
+				{Key: "score", Value: -1},
+				{Key: "message_count", Value: 1},
+			},
+			Options: options.Index().SetBackground(true),
+		},
+		{
+			Keys: bson.D{
+				{Key: "story_id", Value: 1},
+				{Key: "submitted_at", Value: 1},
+			},
+			Options: options.Index().SetBackground(true),
+		},
+		{
+			Keys: bson.D{
+				{Key: "player_id", Value: 1},
+				{Key: "submitted_at", Value: -1},
+			},
+			Options: options.Index().SetBackground(true),
+		},
+	}
+
+	_, err := GetCollection("score_submissions").Indexes().CreateMany(ctx, scoreIndexes)
+	if err != nil {
+		log.Printf("Failed to create score indexes: %v", err)
+	}
+}
@@ -0,0 +1,157 @@
+package db
+
+import (
+	"agent/db/models"
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ErrSessionNotFound is returned when a session lookup matches no document
+var ErrSessionNotFound = errors.New("session not found")
+
+// SessionProgress is a set of discoveries to merge into a session
+type SessionProgress struct {
+	EvidenceIDs  []string
+	LocationIDs  []string
+	ContainerIDs []string
+	Messages     int
+}
+
+// CreateSession inserts a new session and returns its ID
+func CreateSession(ctx context.Context, session *models.SessionDocument) (primitive.ObjectID, error) {
+	session.CreatedAt = time.Now()
+	session.UpdatedAt = session.CreatedAt
+	if session.DiscoveredEvidenceIDs == nil {
+		session.DiscoveredEvidenceIDs = []string{}
+	}
+	if session.UnlockedLocationIDs == nil {
+		session.UnlockedLocationIDs = []string{}
+	}
+	if session.OpenedContainerIDs == nil {
+		session.OpenedContainerIDs = []string{}
+	}
+	if session.AgentIDs == nil {
+		session.AgentIDs = map[string]string{}
+	}
+
+	result, err := GetCollection("sessions").InsertOne(ctx, session)
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+
+	session.ID = result.InsertedID.(primitive.ObjectID)
+	return session.ID, nil
+}
+
+// GetSession fetches a session by its hex ID
+func GetSession(ctx context.Context, sessionID string) (*models.SessionDocument, error) {
+	objID, err := primitive.ObjectIDFromHex(sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	var session models.SessionDocument
+	err = GetCollection("sessions").FindOne(ctx, bson.M{"_id": objID}).Decode(&session)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrSessionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &session, nil
+}
+
+// FindLatestSession returns the player's most recently updated session for a story
+func FindLatestSession(ctx context.Context, playerID string, storyID primitive.ObjectID) (*models.SessionDocument, error) {
+	opts := options.FindOne().SetSort(bson.D{{Key: "updated_at", Value: -1}})
+
+	var session models.SessionDocument
+	err := GetCollection("sessions").FindOne(ctx, bson.M{
+		"player_id": playerID,
+		"story_id":  storyID,
+	}, opts).Decode(&session)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrSessionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &session, nil
+}
+
+// RecordSessionProgress merges discoveries into a session without duplicating IDs
+func RecordSessionProgress(ctx context.Context, sessionID string, progress SessionProgress) error {
+	objID, err := primitive.ObjectIDFromHex(sessionID)
+	if err != nil {
+		return err
+	}
+
+	addToSet := bson.M{}
+	if len(progress.EvidenceIDs) > 0 {
+		addToSet["discovered_evidence_ids"] = bson.M{"$each": progress.EvidenceIDs}
+	}
+	if len(progress.LocationIDs) > 0 {
+		addToSet["unlocked_location_ids"] = bson.M{"$each": progress.LocationIDs}
+	}
+	if len(progress.ContainerIDs) > 0 {
+		addToSet["opened_container_ids"] = bson.M{"$each": progress.ContainerIDs}
+	}
+
+	update := bson.M{"$set": bson.M{"updated_at": time.Now()}}
+	if len(addToSet) > 0 {
+		update["$addToSet"] = addToSet
+	}
+	if progress.Messages > 0 {
+		update["$inc"] = bson.M{"message_count": progress.Messages}
+	}
+
+	_, err = GetCollection("sessions").UpdateOne(ctx, bson.M{"_id": objID}, update)
+	return err
+}
+
+// SetSessionAgent records the agent spawned for a character within a session
+func SetSessionAgent(ctx context.Context, sessionID string, characterID string, agentID string) error {
+	objID, err := primitive.ObjectIDFromHex(sessionID)
+	if err != nil {
+		return err
+	}
+
+	update := bson.M{
+		"$set": bson.M{
+			"agent_ids." + characterID: agentID,
+			"updated_at":               time.Now(),
+		},
+	}
+
+	_, err = GetCollection("sessions").UpdateOne(ctx, bson.M{"_id": objID}, update)
+	return err
+}
+
+// CreateSessionIndexes creates the indexes used for session lookups
+func CreateSessionIndexes() {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	index := mongo.IndexModel{
+		Keys: bson.D{
+			{Key: "player_id", Value: 1},
+			{Key: "story_id", Value: 1},
+			{Key: "updated_at", Value: -1},
+		},
+		Options: options.Index().SetBackground(true),
+	}
+
+	_, err := GetCollection("sessions").Indexes().CreateOne(ctx, index)
+	if err != nil {
+		log.Printf("Failed to create session indexes: %v", err)
+	}
+}
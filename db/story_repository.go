@@ -0,0 +1,42 @@
+package db
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// CreateStoryIndexes creates the indexes the paginated story feed relies on
+// in each of the given story collections
+func CreateStoryIndexes(collectionNames ...string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	storyIndexes := []mongo.IndexModel{
+		{
+			Keys: bson.D{
+				{Key: "created_at", Value: -1},
+				{Key: "_id", Value: -1},
+			},
+			Options: options.Index().SetBackground(true),
+		},
+		{
+			Keys: bson.D{
+				{Key: "theme", Value: 1},
+				{Key: "created_at", Value: -1},
+				{Key: "_id", Value: -1},
+			},
+			Options: options.Index().SetBackground(true),
+		},
+	}
+
+	for _, name := range collectionNames {
+		if _, err := GetCollection(name).Indexes().CreateMany(ctx, storyIndexes); err != nil {
+			log.Printf("Failed to create story indexes on %s: %v", name, err)
+		}
+	}
+}
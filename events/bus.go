@@ -0,0 +1,101 @@
+// Package events fans out investigation events to the clients watching a
+// player session, so they don't need to poll after every action.
+package events
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// Event types
+const (
+	TypeEvidenceRevealed  = "evidence_revealed"
+	TypeLocationRevealed  = "location_revealed"
+	TypeContainerUnlocked = "container_unlocked"
+	TypeCharacterSpawned  = "character_spawned"
+	TypeScoreResult       = "score_result"
+)
+
+// subscriberBuffer is how many events a subscriber can fall behind before
+// further events to it are dropped
+const subscriberBuffer = 32
+
+// Event is a single thing that happened in a session
+type Event struct {
+	Type      string    `json:"type"`
+	SessionID string    `json:"session_id"`
+	Data      any       `json:"data,omitempty"`
+	Time      time.Time `json:"time"`
+}
+
+// Bus delivers published events to the subscribers of each session
+type Bus struct {
+	mu   sync.Mutex
+	subs map[string]map[chan Event]struct{}
+}
+
+// NewBus creates a bus with no subscribers
+func NewBus() *Bus {
+	return &Bus{subs: map[string]map[chan Event]struct{}{}}
+}
+
+// Default is the bus handlers publish to and the WebSocket endpoint reads from
+var Default = NewBus()
+
+// Subscribe returns a channel receiving the session's events and a function
+// that unsubscribes and closes it
+func (b *Bus) Subscribe(sessionID string) (<-chan Event, func()) {
+	ch := make(chan Event, subscriberBuffer)
+
+	b.mu.Lock()
+	if b.subs[sessionID] == nil {
+		b.subs[sessionID] = map[chan Event]struct{}{}
+	}
+	b.subs[sessionID][ch] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			delete(b.subs[sessionID], ch)
+			if len(b.subs[sessionID]) == 0 {
+				delete(b.subs, sessionID)
+			}
+			close(ch)
+		})
+	}
+}
+
+// Publish sends an event to every subscriber of the session without blocking.
+// Subscribers whose buffer is full miss the event.
+func (b *Bus) Publish(sessionID, eventType string, data any) {
+	if sessionID == "" {
+		return
+	}
+	event := Event{Type: eventType, SessionID: sessionID, Data: data, Time: time.Now()}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subs[sessionID] {
+		select {
+		case ch <- event:
+		default:
+			log.Printf("[EVENTS_WARNING] Dropped %s event for a slow subscriber of session %s", eventType, sessionID)
+		}
+	}
+}
+
+// Subscribers returns the number of open subscriptions to a session
+func (b *Bus) Subscribers(sessionID string) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.subs[sessionID])
+}
+
+// Publish sends an event on the default bus
+func Publish(sessionID, eventType string, data any) {
+	Default.Publish(sessionID, eventType, data)
+}
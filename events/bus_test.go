@@ -0,0 +1,52 @@
+package events
+
+import "testing"
+
+func TestBusDeliversOnlyToSessionSubscribers(t *testing.T) {
+	bus := NewBus()
+	a, unsubA := bus.Subscribe("session-a")
+	b, unsubB := bus.Subscribe("session-b")
+	defer unsubB()
+
+	bus.Publish("session-a", TypeEvidenceRevealed, []string{"evid_1"})
+
+	select {
+	case e := <-a:
+		if e.Type != TypeEvidenceRevealed || e.SessionID != "session-a" {
+			t.Errorf("unexpected event %+v", e)
+		}
+	default:
+		t.Fatal("expected session-a subscriber to receive the event")
+	}
+	select {
+	case e := <-b:
+		t.Fatalf("session-b subscriber received %+v", e)
+	default:
+	}
+
+	unsubA()
+	unsubA() // Unsubscribing twice is harmless
+	if _, open := <-a; open {
+		t.Error("expected the channel to be closed after unsubscribing")
+	}
+	if n := bus.Subscribers("session-a"); n != 0 {
+		t.Errorf("expected no subscribers left, got %d", n)
+	}
+}
+
+func TestBusDropsEventsForSlowSubscribers(t *testing.T) {
+	bus := NewBus()
+	ch, unsub := bus.Subscribe("session-a")
+	defer unsub()
+
+	// Publishing never blocks, even when nobody is reading
+	for i := 0; i < subscriberBuffer+10; i++ {
+		bus.Publish("session-a", TypeScoreResult, i)
+	}
+	if len(ch) != subscriberBuffer {
+		t.Errorf("expected %d buffered events, got %d", subscriberBuffer, len(ch))
+	}
+	if first := <-ch; first.Data != 0 {
+		t.Errorf("expected the oldest event first, got %v", first.Data)
+	}
+}
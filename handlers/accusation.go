@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"agent/models"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Accusation is the player's formal answer: who did it and which evidence proves it
+type Accusation struct {
+	CulpritIDs  []string `json:"culprit_ids"`
+	EvidenceIDs []string `json:"evidence_ids"`
+}
+
+// validate checks that every accused character and cited evidence item exists in the story
+func (a *Accusation) validate(story *models.Story) error {
+	if len(a.CulpritIDs) == 0 {
+		return fmt.Errorf("accusation must name at least one culprit")
+	}
+	for _, id := range a.CulpritIDs {
+		if findCharacter(story, id) == nil {
+			return fmt.Errorf("unknown character %s in accusation", id)
+		}
+	}
+
+	storyEvidence := storyEvidenceIDs(story)
+	for _, id := range a.EvidenceIDs {
+		if !storyEvidence[id] {
+			return fmt.Errorf("unknown evidence %s in accusation", id)
+		}
+	}
+	return nil
+}
+
+// describe formats the accusation for the scoring prompt
+func (a *Accusation) describe(story *models.Story) string {
+	var names []string
+	for _, id := range a.CulpritIDs {
+		names = append(names, fmt.Sprintf("%s (%s)", findCharacter(story, id).Name, id))
+	}
+	evidence := "none"
+	if len(a.EvidenceIDs) > 0 {
+		evidence = strings.Join(a.EvidenceIDs, ", ")
+	}
+	return fmt.Sprintf("Accused: %s\nCited evidence: %s", strings.Join(names, ", "), evidence)
+}
+
+// checkAccusation overrides the judge's culprit and evidence grading with a
+// deterministic comparison against the story's solution. The culprit is right
+// only if the accused set matches the solution exactly. Evidence points are the
+// share of key evidence the player both discovered and cited; citing evidence
+// that was never discovered in the session earns nothing.
+func checkAccusation(j scoreJudgment, solution *models.Solution, accusation *Accusation, discoveredEvidenceIDs []string) scoreJudgment {
+	j.CulpritCorrect = sameIDSet(accusation.CulpritIDs, solution.CulpritIDs)
+	j.Breakdown.Culprit = 0
+	if j.CulpritCorrect {
+		j.Breakdown.Culprit = maxCulpritPoints
+	}
+
+	j.EvidenceUsedWell = nil
+	j.EvidenceMissed = nil
+	for _, id := range solution.KeyEvidenceIDs {
+		if slices.Contains(accusation.EvidenceIDs, id) && slices.Contains(discoveredEvidenceIDs, id) {
+			j.EvidenceUsedWell = append(j.EvidenceUsedWell, id)
+		} else {
+			j.EvidenceMissed = append(j.EvidenceMissed, id)
+		}
+	}
+	j.Breakdown.Evidence = maxEvidencePoints
+	if len(solution.KeyEvidenceIDs) > 0 {
+		j.Breakdown.Evidence = maxEvidencePoints * len(j.EvidenceUsedWell) / len(solution.KeyEvidenceIDs)
+	}
+
+	return j
+}
+
+// sameIDSet reports whether a and b hold the same IDs, ignoring order and duplicates
+func sameIDSet(a, b []string) bool {
+	for _, id := range a {
+		if !slices.Contains(b, id) {
+			return false
+		}
+	}
+	for _, id := range b {
+		if !slices.Contains(a, id) {
+			return false
+		}
+	}
+	return len(b) > 0
+}
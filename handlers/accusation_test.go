@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"agent/models"
+	"reflect"
+	"testing"
+)
+
+func TestCheckAccusationOverridesJudge(t *testing.T) {
+	solution := &models.Solution{
+		CulpritIDs:     []string{"char_1", "char_2"},
+		KeyEvidenceIDs: []string{"evid_1", "evid_2", "evid_3", "evid_4"},
+	}
+	// The judge was fooled by a confident but wrong theory
+	judged := scoreJudgment{
+		CulpritCorrect: true,
+		Breakdown:      ScoreBreakdown{Culprit: 30, Motive: 10, Sequence: 10, Evidence: 20, Relationships: 5},
+	}
+
+	// Only one of the two accomplices: wrong, and capped by the rubric
+	j := checkAccusation(judged, solution, &Accusation{CulpritIDs: []string{"char_1"}}, nil)
+	if j.CulpritCorrect || j.Breakdown.Culprit != 0 {
+		t.Errorf("expected a partial accusation to be wrong, got %+v", j)
+	}
+	if j.Breakdown.Evidence != 0 || len(j.EvidenceMissed) != 4 {
+		t.Errorf("expected no evidence credit without citations, got %+v", j)
+	}
+
+	// evid_3 is cited but was never discovered in the session
+	j = checkAccusation(judged, solution, &Accusation{
+		CulpritIDs:  []string{"char_2", "char_1"},
+		EvidenceIDs: []string{"evid_1", "evid_2", "evid_3"},
+	}, []string{"evid_1", "evid_2", "evid_4"})
+	if !j.CulpritCorrect || j.Breakdown.Culprit != maxCulpritPoints {
+		t.Errorf("expected the full culprit set to be correct, got %+v", j)
+	}
+	if j.Breakdown.Evidence != 10 {
+		t.Errorf("evidence points = %d, want 10", j.Breakdown.Evidence)
+	}
+	if !reflect.DeepEqual(j.EvidenceUsedWell, []string{"evid_1", "evid_2"}) || !reflect.DeepEqual(j.EvidenceMissed, []string{"evid_3", "evid_4"}) {
+		t.Errorf("used well %v, missed %v", j.EvidenceUsedWell, j.EvidenceMissed)
+	}
+	// The narrative criteria stay with the judge
+	if j.Breakdown.Motive != 10 || j.Breakdown.Sequence != 10 || j.Breakdown.Relationships != 5 {
+		t.Errorf("narrative criteria changed: %+v", j.Breakdown)
+	}
+}
+
+func TestAccusationValidate(t *testing.T) {
+	story := newScoringStory()
+
+	valid := &Accusation{CulpritIDs: []string{"char_1"}, EvidenceIDs: []string{"evid_3"}}
+	if err := valid.validate(story); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	for _, bad := range []*Accusation{
+		{},
+		{CulpritIDs: []string{"char_9"}},
+		{CulpritIDs: []string{"char_1"}, EvidenceIDs: []string{"evid_9"}},
+	} {
+		if err := bad.validate(story); err == nil {
+			t.Errorf("expected %+v to be rejected", bad)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"agent/db"
+	"agent/models"
+	"agent/validator"
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// AuthorStoryHandler returns the complete story document, spoilers included,
+// for paths like /author/stories/{id}. It must sit behind middleware.RequireAuthorKey.
+func AuthorStoryHandler(w http.ResponseWriter, r *http.Request) {
+	storyID := r.PathValue("id")
+
+	collectionName, ok := resolveStoryCollection(r)
+	if !ok {
+		writeJSONError(w, http.StatusBadRequest, "Unknown collection")
+		return
+	}
+
+	storyObjID, err := primitive.ObjectIDFromHex(storyID)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid story ID")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var story bson.M
+	collection := db.GetCollection(collectionName)
+	if err := collection.FindOne(ctx, bson.M{"_id": storyObjID}).Decode(&story); err != nil {
+		writeJSONError(w, http.StatusNotFound, "Story not found")
+		return
+	}
+
+	story["id"] = story["_id"]
+	delete(story, "_id")
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(story)
+}
+
+// AuthorValidateHandler checks a story for broken references and unreachable
+// content. GET validates a stored story (?id=STORY_ID&collection=ALIAS); POST
+// validates a story document sent in the body, e.g. before importing it.
+// It must sit behind middleware.RequireAuthorKey.
+func AuthorValidateHandler(w http.ResponseWriter, r *http.Request) {
+	var story *models.Story
+
+	switch r.Method {
+	case http.MethodGet:
+		storyID := r.URL.Query().Get("id")
+		if _, err := primitive.ObjectIDFromHex(storyID); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "Invalid story ID")
+			return
+		}
+
+		collectionName, ok := resolveStoryCollection(r)
+		if !ok {
+			writeJSONError(w, http.StatusBadRequest, "Unknown collection")
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		var err error
+		if story, err = fetchStoryFrom(ctx, collectionName, storyID); err != nil {
+			writeJSONError(w, http.StatusNotFound, "Story not found")
+			return
+		}
+
+	case http.MethodPost:
+		story = &models.Story{}
+		if err := json.NewDecoder(r.Body).Decode(story); err != nil {
+			http.Error(w, "Bad request", http.StatusBadRequest)
+			return
+		}
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	report := validator.Validate(story)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
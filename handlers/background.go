@@ -0,0 +1,26 @@
+package handlers
+
+import (
+	"context"
+	"sync"
+)
+
+// background tracks work that outlives the request that started it, such as
+// history compaction, so shutdown can wait for it before flushing agent state
+var background sync.WaitGroup
+
+// WaitBackground blocks until background work has finished or ctx is done
+func WaitBackground(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		background.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"agent/config"
+	"agent/db"
+	"agent/events"
+	"agent/models"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type ContainerUnlockRequest struct {
+	SessionID   string `json:"session_id"`
+	StoryID     string `json:"story_id,omitempty"` // Optional, must match the session's story
+	ContainerID string `json:"container_id"`
+	Code        string `json:"code"`
+}
+
+type ContainerUnlockResponse struct {
+	ContainerID string            `json:"container_id"`
+	Unlocked    bool              `json:"unlocked"`
+	Attempts    int               `json:"attempts"`
+	Evidence    []models.Evidence `json:"evidence,omitempty"`
+	CodeHint    *models.CodeHint  `json:"code_hint,omitempty"`
+}
+
+// ContainerUnlockedEvent is published to the session when a container is opened
+type ContainerUnlockedEvent struct {
+	ContainerID string   `json:"container_id"`
+	LocationID  string   `json:"location_id"`
+	EvidenceIDs []string `json:"evidence_ids"`
+}
+
+func ContainerUnlockHandler(w http.ResponseWriter, r *http.Request) {
+	var req ContainerUnlockRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+
+	if req.SessionID == "" || req.ContainerID == "" {
+		writeJSONError(w, http.StatusBadRequest, "session_id and container_id are required")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	session, ok := loadSession(ctx, w, r, req.SessionID)
+	if !ok {
+		return
+	}
+	storyObjID := session.StoryID
+	if req.StoryID != "" && req.StoryID != storyObjID.Hex() {
+		writeJSONError(w, http.StatusBadRequest, "Session belongs to a different story")
+		return
+	}
+
+	story, err := fetchStoryFrom(ctx, session.StoryCollection, storyObjID.Hex())
+	if err != nil {
+		writeJSONError(w, http.StatusNotFound, "Story not found")
+		return
+	}
+
+	location, container := findContainer(story, req.ContainerID)
+	if container == nil {
+		writeJSONError(w, http.StatusNotFound, "Container not found")
+		return
+	}
+	if !slices.Contains(session.UnlockedLocationIDs, location.ID) {
+		writeJSONError(w, http.StatusForbidden, "Location not unlocked in this session")
+		return
+	}
+
+	attempt, err := db.GetContainerAttempt(ctx, req.SessionID, storyObjID, req.ContainerID)
+	if err != nil {
+		log.Printf("[CONTAINER_ERROR] Failed to load attempts for container %s: %v", req.ContainerID, err)
+		writeJSONError(w, http.StatusInternalServerError, "Failed to load container state")
+		return
+	}
+
+	// Already opened in this session: hand the contents back without counting an attempt
+	if attempt != nil && attempt.Unlocked {
+		writeContainerResponse(w, container, attempt.Attempts, true)
+		return
+	}
+
+	unlocked := !container.IsLocked || codeMatches(container.UnlockCode, req.Code)
+
+	attempt, err = db.RecordContainerAttempt(ctx, req.SessionID, storyObjID, req.ContainerID, unlocked,
+		config.GetContainerMaxAttempts(), config.GetContainerLockout())
+	if errors.Is(err, db.ErrContainerLocked) {
+		seconds := lockoutSeconds(*attempt.LockedUntil, time.Now())
+		log.Printf("[CONTAINER_LOCKOUT] Session %s is locked out of container %s for %ds", req.SessionID, req.ContainerID, seconds)
+		w.Header().Set("Retry-After", strconv.Itoa(seconds))
+		writeJSONError(w, http.StatusTooManyRequests, fmt.Sprintf("Too many wrong codes, try again in %d seconds", seconds))
+		return
+	}
+	if err != nil {
+		log.Printf("[CONTAINER_ERROR] Failed to record attempt for container %s: %v", req.ContainerID, err)
+		writeJSONError(w, http.StatusInternalServerError, "Failed to record attempt")
+		return
+	}
+
+	log.Printf("[CONTAINER_UNLOCK] Session %s attempt %d on container %s: unlocked=%v",
+		req.SessionID, attempt.Attempts, req.ContainerID, unlocked)
+
+	if unlocked {
+		progress := db.SessionProgress{ContainerIDs: []string{container.ID}}
+		for _, e := range container.ContainsEvidence {
+			progress.EvidenceIDs = append(progress.EvidenceIDs, e.ID)
+		}
+		if err := db.RecordSessionProgress(ctx, req.SessionID, progress); err != nil {
+			log.Printf("[CONTAINER_ERROR] Failed to update session %s: %v", req.SessionID, err)
+		}
+		events.Publish(req.SessionID, events.TypeContainerUnlocked, ContainerUnlockedEvent{
+			ContainerID: container.ID,
+			LocationID:  location.ID,
+			EvidenceIDs: progress.EvidenceIDs,
+		})
+	}
+
+	writeContainerResponse(w, container, attempt.Attempts, unlocked)
+}
+
+// writeContainerResponse returns the container's evidence on success and its hint on failure
+func writeContainerResponse(w http.ResponseWriter, container *models.Container, attempts int, unlocked bool) {
+	resp := ContainerUnlockResponse{
+		ContainerID: container.ID,
+		Unlocked:    unlocked,
+		Attempts:    attempts,
+	}
+	if unlocked {
+		resp.Evidence = container.ContainsEvidence
+	} else if container.CodeHint.Description != "" {
+		resp.CodeHint = &container.CodeHint
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// lockoutSeconds returns the whole seconds left until lockedUntil, at least one
+// so a client never retries straight into the lockout
+func lockoutSeconds(lockedUntil, now time.Time) int {
+	return max(1, int(math.Ceil(lockedUntil.Sub(now).Seconds())))
+}
+
+// codeMatches compares a submitted code to the container's code, ignoring case
+// and surrounding whitespace
+func codeMatches(expected, submitted string) bool {
+	expected = strings.ToLower(strings.TrimSpace(expected))
+	submitted = strings.ToLower(strings.TrimSpace(submitted))
+	if expected == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(submitted)) == 1
+}
+
+// findContainer returns the container with the given ID and the location holding it,
+// or nils if no location has it
+func findContainer(story *models.Story, containerID string) (*models.Location, *models.Container) {
+	for i := range story.Story.Locations {
+		location := &story.Story.Locations[i]
+		for j := range location.Containers {
+			if location.Containers[j].ID == containerID {
+				return location, &location.Containers[j]
+			}
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"agent/models"
+	"testing"
+	"time"
+)
+
+func TestCodeMatches(t *testing.T) {
+	tests := []struct {
+		expected, submitted string
+		want                bool
+	}{
+		{"1987", "1987", true},
+		{"Raven", "  raven ", true},
+		{"1987", "1988", false},
+		{"1987", "", false},
+		{"", "", false}, // A locked container without a code can never be opened by guessing
+	}
+
+	for _, tt := range tests {
+		if got := codeMatches(tt.expected, tt.submitted); got != tt.want {
+			t.Errorf("codeMatches(%q, %q) = %v, want %v", tt.expected, tt.submitted, got, tt.want)
+		}
+	}
+}
+
+func TestFindContainer(t *testing.T) {
+	story := &models.Story{Story: models.StoryContent{
+		Locations: []models.Location{
+			{ID: "loc_1"},
+			{ID: "loc_2", Containers: []models.Container{{ID: "safe_1", UnlockCode: "1987"}}},
+		},
+	}}
+
+	if loc, c := findContainer(story, "safe_1"); c == nil || c.UnlockCode != "1987" || loc.ID != "loc_2" {
+		t.Errorf("expected to find safe_1 in loc_2, got %+v in %+v", c, loc)
+	}
+	if loc, c := findContainer(story, "safe_2"); c != nil || loc != nil {
+		t.Errorf("expected nil for unknown container, got %+v", c)
+	}
+}
+
+func TestLockoutSeconds(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name  string
+		until time.Time
+		want  int
+	}{
+		{"whole minutes", now.Add(5 * time.Minute), 300},
+		{"rounds up", now.Add(1500 * time.Millisecond), 2},
+		{"about to end", now.Add(time.Millisecond), 1},
+		{"already over", now.Add(-time.Second), 1},
+	}
+
+	for _, tt := range tests {
+		if got := lockoutSeconds(tt.until, now); got != tt.want {
+			t.Errorf("%s: lockoutSeconds = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
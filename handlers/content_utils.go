@@ -25,6 +25,10 @@ func extractClientContent(fullContent string, role string) string {
 	locationRegex := regexp.MustCompile(`\[CURRENT LOCATION:[^\]]*\]\s*`)
 	content = locationRegex.ReplaceAllString(content, "")
 
+	// Remove withheld evidence tags added by the standing rules
+	withheldRegex := regexp.MustCompile(`\[WITHHELD EVIDENCE:[^\]]*\]\s*`)
+	content = withheldRegex.ReplaceAllString(content, "")
+
 	// Remove evidence presentation section
 	// Pattern: [USER IS PRESENTING THE FOLLOWING EVIDENCE TO YOU]: and everything after it
 	evidenceRegex := regexp.MustCompile(`\n*\[USER IS PRESENTING THE FOLLOWING EVIDENCE TO YOU\]:[\s\S]*$`)
@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"agent/db"
+	"context"
+)
+
+// conversationStore persists agent turns. The Mongo implementation is used in
+// production; tests swap in an in-memory store.
+type conversationStore interface {
+	SaveMessage(ctx context.Context, agentID, fullContent, clientContent, role string, index int, revealedEvidences, revealedLocations []string) error
+	DeleteMessage(ctx context.Context, agentID string, index int) error
+	UpdateReveals(ctx context.Context, agentID string, revealedEvidenceIDs, revealedLocationIDs map[string]bool) error
+	UpdateStanding(ctx context.Context, agentID string, reputation, intimidation int, presentedEvidenceIDs map[string]bool) error
+	RecordSessionProgress(ctx context.Context, sessionID string, progress db.SessionProgress) error
+	DiscoveredEvidence(ctx context.Context, sessionID string) ([]string, error)
+	SaveSummary(ctx context.Context, agentID, summary string, throughIndex int) error
+}
+
+var conversations conversationStore = mongoConversationStore{}
+
+type mongoConversationStore struct{}
+
+func (mongoConversationStore) SaveMessage(ctx context.Context, agentID, fullContent, clientContent, role string, index int, revealedEvidences, revealedLocations []string) error {
+	return db.SaveConversationMessageWithVersions(ctx, agentID, fullContent, clientContent, role, index, revealedEvidences, revealedLocations)
+}
+
+func (mongoConversationStore) DeleteMessage(ctx context.Context, agentID string, index int) error {
+	return db.DeleteConversationMessage(ctx, agentID, index)
+}
+
+func (mongoConversationStore) UpdateReveals(ctx context.Context, agentID string, revealedEvidenceIDs, revealedLocationIDs map[string]bool) error {
+	return db.UpdateAgentReveals(ctx, agentID, revealedEvidenceIDs, revealedLocationIDs)
+}
+
+func (mongoConversationStore) UpdateStanding(ctx context.Context, agentID string, reputation, intimidation int, presentedEvidenceIDs map[string]bool) error {
+	return db.UpdateAgentStanding(ctx, agentID, reputation, intimidation, presentedEvidenceIDs)
+}
+
+func (mongoConversationStore) SaveSummary(ctx context.Context, agentID, summary string, throughIndex int) error {
+	return db.SaveConversationSummary(ctx, agentID, summary, throughIndex)
+}
+
+func (mongoConversationStore) RecordSessionProgress(ctx context.Context, sessionID string, progress db.SessionProgress) error {
+	return db.RecordSessionProgress(ctx, sessionID, progress)
+}
+
+func (mongoConversationStore) DiscoveredEvidence(ctx context.Context, sessionID string) ([]string, error) {
+	session, err := db.GetSession(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	return session.DiscoveredEvidenceIDs, nil
+}
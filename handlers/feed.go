@@ -2,127 +2,261 @@ package handlers
 
 import (
 	"agent/db"
+	"agent/models"
 	"context"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
 	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+const (
+	defaultFeedLimit = 20
+	maxFeedLimit     = 100
+)
+
+// FeedItem is the summary of a story shown in the feed
+type FeedItem struct {
+	ID            string    `json:"id"`
+	Title         string    `json:"title"`
+	Description   string    `json:"description"`
+	CoverImageURL string    `json:"cover_image_url,omitempty"`
+	Theme         string    `json:"theme,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// FeedResponse is one page of the feed. NextCursor is empty on the last page.
+type FeedResponse struct {
+	Stories    []FeedItem `json:"stories"`
+	Count      int        `json:"count"`
+	NextCursor string     `json:"next_cursor,omitempty"`
+}
+
+// feedQuery holds the parsed feed query parameters
+type feedQuery struct {
+	Limit     int
+	Cursor    *feedCursor
+	Ascending bool
+	Theme     string
+	Search    string
+}
+
+// feedCursor marks the last story of a page; the next page starts right after it
+type feedCursor struct {
+	CreatedAt time.Time
+	ID        primitive.ObjectID
+}
+
 func FeedHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	serveFeed(w, r, "stories")
+}
+
+func FeedHandlerV2(w http.ResponseWriter, r *http.Request) {
+	collectionName, ok := resolveStoryCollection(r)
+	if !ok {
+		writeJSONError(w, http.StatusBadRequest, "Unknown collection")
+		return
+	}
+
+	serveFeed(w, r, collectionName)
+}
+
+// serveFeed writes one page of the feed for the given story collection
+func serveFeed(w http.ResponseWriter, r *http.Request, collectionName string) {
+	query, err := parseFeedQuery(r.URL.Query())
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	collection := db.GetCollection("stories")
-	cursor, err := collection.Find(ctx, bson.M{})
+	direction := -1
+	if query.Ascending {
+		direction = 1
+	}
+	findOptions := options.Find().
+		SetProjection(bson.M{
+			"_id":                   1,
+			"theme":                 1,
+			"created_at":            1,
+			"updated_at":            1,
+			"story.title":           1,
+			"story.cover_image_url": 1,
+			"story.news_article":    1,
+		}).
+		SetSort(bson.D{{Key: "created_at", Value: direction}, {Key: "_id", Value: direction}}).
+		SetLimit(int64(query.Limit + 1)) // One extra to know whether another page follows
+
+	collection := db.GetCollection(collectionName)
+	cursor, err := collection.Find(ctx, buildFeedFilter(query), findOptions)
 	if err != nil {
+		log.Printf("[FEED_ERROR] Failed to fetch stories from %s: %v", collectionName, err)
 		http.Error(w, "Failed to fetch stories", http.StatusInternalServerError)
 		return
 	}
 	defer cursor.Close(ctx)
 
-	var stories []bson.M
+	var stories []models.Story
 	if err = cursor.All(ctx, &stories); err != nil {
+		log.Printf("[FEED_ERROR] Failed to decode stories from %s: %v", collectionName, err)
 		http.Error(w, "Failed to decode stories", http.StatusInternalServerError)
 		return
 	}
 
-	feedItems := make([]bson.M, 0, len(stories))
+	resp := FeedResponse{Stories: make([]FeedItem, 0, len(stories))}
+	if len(stories) > query.Limit {
+		stories = stories[:query.Limit]
+		last := stories[len(stories)-1]
+		resp.NextCursor = encodeFeedCursor(feedCursor{CreatedAt: last.CreatedAt, ID: last.ID})
+	}
 	for _, s := range stories {
-		item := bson.M{
-			"id":         s["_id"],
-			"created_at": s["created_at"],
-			"updated_at": s["updated_at"],
-		}
-		if story, ok := s["story"].(bson.M); ok {
-			item["title"] = story["title"]
-			item["cover_image_url"] = story["cover_image_url"]
-			if newsArticle, ok := story["news_article"].(bson.M); ok {
-				item["description"] = newsArticle["content"]
-			}
-		}
-		feedItems = append(feedItems, item)
+		resp.Stories = append(resp.Stories, FeedItem{
+			ID:            s.ID.Hex(),
+			Title:         s.Story.Title,
+			Description:   s.Story.NewsArticle.Content,
+			CoverImageURL: s.Story.CoverImageURL,
+			Theme:         s.Theme,
+			CreatedAt:     s.CreatedAt,
+			UpdatedAt:     s.UpdatedAt,
+		})
 	}
+	resp.Count = len(resp.Stories)
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(feedItems)
+	json.NewEncoder(w).Encode(resp)
 }
 
-func FeedHandlerV2(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
+// parseFeedQuery reads limit, cursor, sort, theme and q from the query string
+func parseFeedQuery(values url.Values) (feedQuery, error) {
+	query := feedQuery{
+		Limit:  defaultFeedLimit,
+		Theme:  strings.TrimSpace(values.Get("theme")),
+		Search: strings.TrimSpace(values.Get("q")),
 	}
 
-	collectionName := r.URL.Query().Get("collection")
-	if collectionName == "" {
-		collectionName = "stories"
+	if limit := values.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n <= 0 {
+			return query, errors.New("limit must be a positive integer")
+		}
+		query.Limit = min(n, maxFeedLimit)
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
+	switch values.Get("sort") {
+	case "", "newest":
+	case "oldest":
+		query.Ascending = true
+	default:
+		return query, errors.New("sort must be newest or oldest")
+	}
 
-	collection := db.GetCollection(collectionName)
-	cursor, err := collection.Find(ctx, bson.M{})
-	if err != nil {
-		http.Error(w, "Failed to fetch stories", http.StatusInternalServerError)
-		return
+	if c := values.Get("cursor"); c != "" {
+		cursor, err := decodeFeedCursor(c)
+		if err != nil {
+			return query, errors.New("invalid cursor")
+		}
+		query.Cursor = &cursor
 	}
-	defer cursor.Close(ctx)
 
-	var stories []bson.M
-	if err = cursor.All(ctx, &stories); err != nil {
-		http.Error(w, "Failed to decode stories", http.StatusInternalServerError)
-		return
+	return query, nil
+}
+
+// buildFeedFilter turns a feed query into a Mongo filter. The cursor condition
+// continues after the cursor story in (created_at, _id) order.
+func buildFeedFilter(query feedQuery) bson.M {
+	var conditions []bson.M
+
+	if query.Theme != "" {
+		conditions = append(conditions, bson.M{"theme": query.Theme})
 	}
 
-	feedItems := make([]bson.M, 0, len(stories))
-	for _, s := range stories {
-		item := bson.M{
-			"id":         s["_id"],
-			"created_at": s["created_at"],
-			"updated_at": s["updated_at"],
-		}
-		if story, ok := s["story"].(bson.M); ok {
-			item["title"] = story["title"]
-			item["cover_image_url"] = story["cover_image_url"]
-			if newsArticle, ok := story["news_article"].(bson.M); ok {
-				item["description"] = newsArticle["content"]
-			}
+	if query.Search != "" {
+		pattern := primitive.Regex{Pattern: regexp.QuoteMeta(query.Search), Options: "i"}
+		conditions = append(conditions, bson.M{"$or": bson.A{
+			bson.M{"story.title": pattern},
+			bson.M{"story.news_article.title": pattern},
+			bson.M{"story.news_article.content": pattern},
+		}})
+	}
+
+	if query.Cursor != nil {
+		op := "$lt"
+		if query.Ascending {
+			op = "$gt"
 		}
-		feedItems = append(feedItems, item)
+		conditions = append(conditions, bson.M{"$or": bson.A{
+			bson.M{"created_at": bson.M{op: query.Cursor.CreatedAt}},
+			bson.M{"created_at": query.Cursor.CreatedAt, "_id": bson.M{op: query.Cursor.ID}},
+		}})
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(feedItems)
+	switch len(conditions) {
+	case 0:
+		return bson.M{}
+	case 1:
+		return conditions[0]
+	default:
+		return bson.M{"$and": conditions}
+	}
 }
 
-func StoryDetailHandlerV2(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
+// encodeFeedCursor returns an opaque cursor token for the given story position
+func encodeFeedCursor(c feedCursor) string {
+	raw := fmt.Sprintf("%d:%s", c.CreatedAt.UnixMilli(), c.ID.Hex())
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// decodeFeedCursor parses a token produced by encodeFeedCursor
+func decodeFeedCursor(token string) (feedCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return feedCursor{}, err
 	}
 
+	millis, hexID, found := strings.Cut(string(raw), ":")
+	if !found {
+		return feedCursor{}, errors.New("malformed cursor")
+	}
+	ms, err := strconv.ParseInt(millis, 10, 64)
+	if err != nil {
+		return feedCursor{}, err
+	}
+	id, err := primitive.ObjectIDFromHex(hexID)
+	if err != nil {
+		return feedCursor{}, err
+	}
+
+	return feedCursor{CreatedAt: time.UnixMilli(ms).UTC(), ID: id}, nil
+}
+
+func StoryDetailHandlerV2(w http.ResponseWriter, r *http.Request) {
 	storyID := r.URL.Query().Get("id")
 	if storyID == "" {
 		http.Error(w, "Story ID is required", http.StatusBadRequest)
 		return
 	}
 
-	collectionName := r.URL.Query().Get("collection")
-	if collectionName == "" {
-		collectionName = "stories"
+	collectionName, ok := resolveStoryCollection(r)
+	if !ok {
+		writeJSONError(w, http.StatusBadRequest, "Unknown collection")
+		return
 	}
 
-	storyObjID, err := primitive.ObjectIDFromHex(storyID)
-	if err != nil {
+	if _, err := primitive.ObjectIDFromHex(storyID); err != nil {
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusBadRequest)
 		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid story ID"})
@@ -132,9 +266,7 @@ func StoryDetailHandlerV2(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	var story bson.M
-	collection := db.GetCollection(collectionName)
-	err = collection.FindOne(ctx, bson.M{"_id": storyObjID}).Decode(&story)
+	story, err := fetchStoryFrom(ctx, collectionName, storyID)
 	if err != nil {
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusNotFound)
@@ -142,27 +274,23 @@ func StoryDetailHandlerV2(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	story["id"] = story["_id"]
-	delete(story, "_id")
-
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(story)
+	json.NewEncoder(w).Encode(models.NewPublicStory(story))
 }
 
 func StoryDetailHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
 	storyID := r.URL.Query().Get("id")
 	if storyID == "" {
 		http.Error(w, "Story ID is required", http.StatusBadRequest)
 		return
 	}
 
-	storyObjID, err := primitive.ObjectIDFromHex(storyID)
-	if err != nil {
+	serveStoryDetail(w, storyID)
+}
+
+// serveStoryDetail writes the public view of a story from the default collection
+func serveStoryDetail(w http.ResponseWriter, storyID string) {
+	if _, err := primitive.ObjectIDFromHex(storyID); err != nil {
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusBadRequest)
 		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid story ID"})
@@ -172,9 +300,7 @@ func StoryDetailHandler(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	var story bson.M
-	collection := db.GetCollection("stories")
-	err = collection.FindOne(ctx, bson.M{"_id": storyObjID}).Decode(&story)
+	story, err := fetchStory(ctx, storyID)
 	if err != nil {
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusNotFound)
@@ -182,9 +308,6 @@ func StoryDetailHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	story["id"] = story["_id"]
-	delete(story, "_id")
-
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(story)
+	json.NewEncoder(w).Encode(models.NewPublicStory(story))
 }
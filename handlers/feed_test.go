@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestParseFeedQuery(t *testing.T) {
+	q, err := parseFeedQuery(url.Values{})
+	if err != nil || q.Limit != defaultFeedLimit || q.Ascending || q.Cursor != nil {
+		t.Errorf("unexpected defaults %+v (err %v)", q, err)
+	}
+
+	q, err = parseFeedQuery(url.Values{"limit": {"500"}, "sort": {"oldest"}, "theme": {" noir "}, "q": {"lighthouse"}})
+	if err != nil {
+		t.Fatalf("parseFeedQuery returned error: %v", err)
+	}
+	if q.Limit != maxFeedLimit || !q.Ascending || q.Theme != "noir" || q.Search != "lighthouse" {
+		t.Errorf("unexpected query %+v", q)
+	}
+
+	for _, bad := range []url.Values{
+		{"limit": {"0"}},
+		{"limit": {"ten"}},
+		{"sort": {"title"}},
+		{"cursor": {"not-a-cursor"}},
+	} {
+		if _, err := parseFeedQuery(bad); err == nil {
+			t.Errorf("expected error for %v", bad)
+		}
+	}
+}
+
+func TestFeedCursorRoundTrip(t *testing.T) {
+	want := feedCursor{
+		CreatedAt: time.Date(2026, 2, 20, 0, 50, 26, 330_000_000, time.UTC),
+		ID:        primitive.NewObjectID(),
+	}
+
+	got, err := decodeFeedCursor(encodeFeedCursor(want))
+	if err != nil {
+		t.Fatalf("decodeFeedCursor returned error: %v", err)
+	}
+	if !got.CreatedAt.Equal(want.CreatedAt) || got.ID != want.ID {
+		t.Errorf("round trip = %+v, want %+v", got, want)
+	}
+}
+
+func TestBuildFeedFilter(t *testing.T) {
+	if got := buildFeedFilter(feedQuery{}); len(got) != 0 {
+		t.Errorf("expected empty filter, got %v", got)
+	}
+
+	if got := buildFeedFilter(feedQuery{Theme: "noir"}); !reflect.DeepEqual(got, bson.M{"theme": "noir"}) {
+		t.Errorf("unexpected theme filter %v", got)
+	}
+
+	// Search input is matched literally, not as a regular expression
+	got := buildFeedFilter(feedQuery{Search: "a.b*"})
+	or := got["$or"].(bson.A)
+	if pattern := or[0].(bson.M)["story.title"].(primitive.Regex); pattern.Pattern != `a\.b\*` || pattern.Options != "i" {
+		t.Errorf("unexpected search pattern %+v", pattern)
+	}
+
+	cursor := &feedCursor{CreatedAt: time.Unix(100, 0), ID: primitive.NewObjectID()}
+	got = buildFeedFilter(feedQuery{Theme: "noir", Cursor: cursor, Ascending: true})
+	and, ok := got["$and"].([]bson.M)
+	if !ok || len(and) != 2 {
+		t.Fatalf("expected theme and cursor conditions, got %v", got)
+	}
+	after := and[1]["$or"].(bson.A)
+	if !reflect.DeepEqual(after[0], bson.M{"created_at": bson.M{"$gt": cursor.CreatedAt}}) {
+		t.Errorf("ascending cursor should continue with later stories, got %v", after[0])
+	}
+}
@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"agent/auth"
+	"agent/config"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+)
+
+type GuestTokenResponse struct {
+	Token     string    `json:"token"`
+	PlayerID  string    `json:"player_id"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// GuestTokenHandler issues a token for a new anonymous player. The web client
+// calls it on first visit and keeps the token for later requests.
+func GuestTokenHandler(w http.ResponseWriter, r *http.Request) {
+	verifier := auth.Default()
+	if verifier == nil {
+		writeJSONError(w, http.StatusNotFound, "Authentication is disabled")
+		return
+	}
+
+	token, playerID, expiresAt, err := verifier.IssueGuestToken(config.GetGuestTokenTTL())
+	if errors.Is(err, auth.ErrGuestTokensDisabled) {
+		writeJSONError(w, http.StatusNotFound, "Guest tokens are not enabled")
+		return
+	}
+	if err != nil {
+		log.Printf("[AUTH_ERROR] Failed to issue guest token: %v", err)
+		writeJSONError(w, http.StatusInternalServerError, "Failed to issue guest token")
+		return
+	}
+
+	log.Printf("[AUTH] Issued guest token for %s", playerID)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(GuestTokenResponse{Token: token, PlayerID: playerID, ExpiresAt: expiresAt})
+}
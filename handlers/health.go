@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"agent/config"
+	"agent/db"
+	"agent/llm"
+	"agent/version"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Readiness check results
+const (
+	CheckOK   = "ok"
+	CheckFail = "fail"
+)
+
+type HealthResponse struct {
+	Status string `json:"status"`
+}
+
+// ReadinessCheck is the result of checking one dependency
+type ReadinessCheck struct {
+	Status    string    `json:"status"`
+	LatencyMS int64     `json:"latency_ms"`
+	Error     string    `json:"error,omitempty"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+type ReadinessResponse struct {
+	Status string                    `json:"status"` // "ready" or "not_ready"
+	Checks map[string]ReadinessCheck `json:"checks"`
+}
+
+type VersionResponse struct {
+	version.Info
+	LLMProvider string `json:"llm_provider"`
+	Model       string `json:"model"`
+}
+
+// llmReadiness caches the LLM provider check between /readyz probes
+var llmReadiness cachedCheck
+
+// HealthzHandler reports that the process is up and serving requests. It checks
+// no dependencies, so a failing database doesn't get the process restarted.
+func HealthzHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(HealthResponse{Status: "ok"})
+}
+
+// ReadyzHandler reports whether the server can handle traffic: MongoDB must
+// answer a ping and, when READY_CHECK_LLM is set, the LLM provider must be
+// reachable. The LLM result is cached for READY_LLM_CACHE_TTL. Answers 503
+// when any check fails.
+func ReadyzHandler(w http.ResponseWriter, r *http.Request) {
+	resp := ReadinessResponse{Status: "ready", Checks: map[string]ReadinessCheck{}}
+
+	resp.Checks["mongo"] = runCheck(2*time.Second, pingMongo)
+	if config.GetReadyCheckLLM() {
+		resp.Checks["llm"] = llmReadiness.get(config.GetReadyLLMCacheTTL(), func() ReadinessCheck {
+			return runCheck(5*time.Second, pingLLM)
+		})
+	}
+
+	status := http.StatusOK
+	for _, check := range resp.Checks {
+		if check.Status != CheckOK {
+			resp.Status = "not_ready"
+			status = http.StatusServiceUnavailable
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// VersionHandler returns the build metadata and the model the server talks to
+func VersionHandler(w http.ResponseWriter, r *http.Request) {
+	resp := VersionResponse{
+		Info:        version.Get(),
+		LLMProvider: config.GetLLMProvider(),
+	}
+	switch resp.LLMProvider {
+	case "gemini":
+		resp.Model = config.GetGeminiModel()
+	case "openai":
+		resp.Model = config.GetOpenAIModel()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// runCheck runs check with a timeout and records how long it took
+func runCheck(timeout time.Duration, check func(ctx context.Context) error) ReadinessCheck {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := check(ctx)
+	result := ReadinessCheck{
+		Status:    CheckOK,
+		LatencyMS: time.Since(start).Milliseconds(),
+		CheckedAt: start.UTC(),
+	}
+	if err != nil {
+		result.Status = CheckFail
+		result.Error = err.Error()
+	}
+	return result
+}
+
+func pingMongo(ctx context.Context) error {
+	client := db.GetClient()
+	if client == nil {
+		return errors.New("not connected")
+	}
+	return client.Ping(ctx, nil)
+}
+
+func pingLLM(ctx context.Context) error {
+	gen := llm.Default()
+	if gen == nil {
+		return errors.New("no provider configured")
+	}
+	return llm.Ping(ctx, gen)
+}
+
+// cachedCheck remembers the last result of a check for a while. Concurrent
+// callers wait for a single check rather than each running their own.
+type cachedCheck struct {
+	mu     sync.Mutex
+	result ReadinessCheck
+	valid  bool
+}
+
+// get returns the cached result if it is younger than ttl, otherwise runs check
+func (c *cachedCheck) get(ttl time.Duration, check func() ReadinessCheck) ReadinessCheck {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.valid && time.Since(c.result.CheckedAt) < ttl {
+		return c.result
+	}
+	c.result = check()
+	c.valid = true
+	return c.result
+}
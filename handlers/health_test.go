@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCachedCheckReusesResultWithinTTL(t *testing.T) {
+	var c cachedCheck
+	calls := 0
+	check := func() ReadinessCheck {
+		calls++
+		return ReadinessCheck{Status: CheckOK, CheckedAt: time.Now()}
+	}
+
+	c.get(time.Minute, check)
+	c.get(time.Minute, check)
+	if calls != 1 {
+		t.Errorf("check ran %d times within the TTL, want 1", calls)
+	}
+
+	c.get(0, check)
+	if calls != 2 {
+		t.Errorf("check ran %d times after the TTL, want 2", calls)
+	}
+}
+
+func TestReadyzFailsWithoutMongo(t *testing.T) {
+	rr := httptest.NewRecorder()
+	ReadyzHandler(rr, httptest.NewRequest("GET", "/readyz", nil))
+
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want %d", rr.Code, http.StatusServiceUnavailable)
+	}
+	var resp ReadinessResponse
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatalf("invalid response body: %v", err)
+	}
+	if resp.Status != "not_ready" || resp.Checks["mongo"].Status != CheckFail {
+		t.Errorf("unexpected response %+v", resp)
+	}
+}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"agent/auth"
 	"agent/db"
 	"context"
 	"encoding/json"
@@ -44,11 +45,6 @@ type chatMessageDocument struct {
 }
 
 func HistoryHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost && r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
 	var req HistoryRequest
 
 	if r.Method == http.MethodGet {
@@ -88,6 +84,11 @@ func HistoryHandler(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
+	if !canReadHistory(ctx, r, req.SessionID) {
+		writeJSONError(w, http.StatusForbidden, "History belongs to another player")
+		return
+	}
+
 	collection := db.GetDataStoreCollection("chat_messages")
 	if collection == nil {
 		http.Error(w, "Datastore not initialized", http.StatusInternalServerError)
@@ -149,6 +150,22 @@ func HistoryHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
+// canReadHistory reports whether the requesting player owns the agent or session
+// the history belongs to. Older clients pass an agent ID as the session ID.
+func canReadHistory(ctx context.Context, r *http.Request, id string) bool {
+	if auth.PlayerID(r.Context()) == "" {
+		return true
+	}
+
+	if session, err := db.GetSession(ctx, id); err == nil {
+		return auth.Owns(r.Context(), session.PlayerID)
+	}
+	if playerID, err := db.GetAgentOwner(ctx, id); err == nil {
+		return auth.Owns(r.Context(), playerID)
+	}
+	return false
+}
+
 // normalizeContentPayload attempts to convert the stored string content into JSON for responses
 func normalizeContentPayload(content string) json.RawMessage {
 	if content == "" {
@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"agent/agent"
+	"agent/config"
+	"agent/llm"
+	"agent/prompts"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+)
+
+// scheduleCompaction compacts the agent's history in the background once the
+// current turn releases the agent lock, so the player doesn't wait for it.
+// The caller must hold the agent lock.
+func scheduleCompaction(agentObj *agent.Agent) {
+	if !agentObj.NeedsCompaction(config.GetHistoryTokenBudget()) {
+		return
+	}
+
+	// The turn's pin ends when it returns, so hold one for the compaction
+	agentObj.Pin()
+	background.Add(1)
+	go func() {
+		defer background.Done()
+		defer agentObj.Unpin()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+		defer cancel()
+
+		if err := compactHistory(ctx, agentObj, config.GetHistoryTokenBudget(), 2*config.GetHistoryKeepTurns()); err != nil {
+			log.Printf("[COMPACTION_ERROR] Failed to compact history for agent %s: %v", agentObj.ID, err)
+		}
+	}()
+}
+
+// compactHistory folds the agent's older turns into its rolling summary when the
+// history is over budget, keeping the most recent keep messages verbatim. The
+// agent lock is only held to read the turns and to apply the summary, not while
+// the model writes it, so the player's next message isn't kept waiting. If a turn
+// or another compaction changed the agent meanwhile the summary is dropped; the
+// next turn schedules a fresh one. The summary is stored before the in-memory
+// history is replaced, so a failure leaves the agent as it was.
+// The caller must not hold the agent lock.
+func compactHistory(ctx context.Context, agentObj *agent.Agent, budget, keep int) error {
+	agentObj.Lock()
+	if !agentObj.NeedsCompaction(budget) {
+		agentObj.Unlock()
+		return nil
+	}
+	n := agentObj.CompactableMessages(keep)
+	if n == 0 {
+		agentObj.Unlock()
+		return nil
+	}
+
+	prompt := prompts.ConstructHistorySummaryPrompt(agentObj.CharacterName, agentObj.Summary,
+		formatTranscript(agentObj.CharacterName, agentObj.History[1:1+n]),
+		slices.Sorted(maps.Keys(agentObj.RevealedEvidenceIDs)), slices.Sorted(maps.Keys(agentObj.RevealedLocationIDs)))
+
+	// The kept messages are the most recent ones, so everything before them is covered
+	kept := len(agentObj.History) - 1 - n
+	throughIndex := agentObj.NextIndex - 1 - kept
+	nextIndex, summaryThrough := agentObj.NextIndex, agentObj.SummaryThroughIndex
+	agentObj.Unlock()
+
+	summary, err := llm.Default().Generate(ctx, prompt, llm.Options{Purpose: llm.PurposeSummary})
+	if err != nil {
+		return err
+	}
+	summary = strings.TrimSpace(summary)
+	if summary == "" {
+		return fmt.Errorf("model returned an empty summary")
+	}
+
+	agentObj.Lock()
+	defer agentObj.Unlock()
+	if agentObj.NextIndex != nextIndex || agentObj.SummaryThroughIndex != summaryThrough {
+		log.Printf("[COMPACTION] Agent %s changed while its summary was generated, dropping the summary", agentObj.ID)
+		return nil
+	}
+	if err := conversations.SaveSummary(ctx, agentObj.ID, summary, throughIndex); err != nil {
+		return err
+	}
+
+	before := agent.EstimateTokens(agentObj.History[1:])
+	agentObj.ApplySummary(summary, n, throughIndex)
+	log.Printf("[COMPACTION] Folded %d messages of agent %s into its summary (through index %d, ~%d -> ~%d tokens)",
+		n, agentObj.ID, throughIndex, before, agent.EstimateTokens(agentObj.History[1:]))
+	return nil
+}
+
+// formatTranscript renders turns as a readable exchange for the summary prompt
+func formatTranscript(characterName string, messages []llm.Message) string {
+	var sb strings.Builder
+	for _, msg := range messages {
+		if msg.Role == llm.RoleUser {
+			sb.WriteString(fmt.Sprintf("Investigator: %s\n\n", msg.Content))
+			continue
+		}
+
+		var reply agentReply
+		if err := json.Unmarshal([]byte(msg.Content), &reply); err != nil || reply.Reply == "" {
+			sb.WriteString(fmt.Sprintf("%s: %s\n\n", characterName, msg.Content))
+			continue
+		}
+		sb.WriteString(fmt.Sprintf("%s: %s\n", characterName, reply.Reply))
+		if len(reply.RevealedEvidences) > 0 {
+			sb.WriteString(fmt.Sprintf("(%s handed over: %s)\n", characterName, strings.Join(reply.RevealedEvidences, ", ")))
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"agent/agent"
+	"agent/llm"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestCompactHistoryStoresRollingSummary(t *testing.T) {
+	store := &memoryConversationStore{indexes: map[int]string{}}
+	prevStore := conversations
+	conversations = store
+	defer func() { conversations = prevStore }()
+
+	var prompts []string
+	prevGen := llm.Default()
+	llm.SetDefault(&llm.Fake{Respond: func(history []llm.Message, opts llm.Options) (string, error) {
+		prompts = append(prompts, history[0].Content)
+		return fmt.Sprintf("- Summary %d: Agnes promised to show the ledger.", len(prompts)), nil
+	}})
+	defer llm.SetDefault(prevGen)
+
+	agentObj := &agent.Agent{
+		ID:                  "agent-1",
+		CharacterName:       "Agnes",
+		History:             []llm.Message{llm.NewMessage(llm.RoleModel, "You are Agnes.")},
+		RevealedEvidenceIDs: map[string]bool{"evid_ledger": true},
+		RevealedLocationIDs: map[string]bool{},
+		NextIndex:           1,
+	}
+	addTurns := func(n int) {
+		for i := 0; i < n; i++ {
+			agentObj.History = append(agentObj.History,
+				llm.NewMessage(llm.RoleUser, "Will you show me the ledger?"),
+				llm.NewMessage(llm.RoleModel, `{"reply": "Tomorrow, I promise.", "revealed_evidences": ["evid_ledger"]}`))
+			agentObj.NextIndex += 2
+		}
+	}
+
+	// Under budget nothing happens
+	addTurns(2)
+	if err := compactHistory(context.Background(), agentObj, 1000, 4); err != nil || len(store.summaries) != 0 {
+		t.Fatalf("expected no compaction under budget, got %v and %d summaries", err, len(store.summaries))
+	}
+
+	addTurns(8) // 10 turns, indexes 1-20
+	if err := compactHistory(context.Background(), agentObj, 100, 4); err != nil {
+		t.Fatalf("compactHistory returned error: %v", err)
+	}
+	if len(store.summaries) != 1 || store.summaries[0].throughIndex != 16 {
+		t.Fatalf("expected one summary through index 16, got %+v", store.summaries)
+	}
+	if len(agentObj.History) != 5 || !strings.Contains(agentObj.History[0].Content, "Summary 1") {
+		t.Errorf("expected system prompt with summary plus 4 kept messages, got %d messages", len(agentObj.History))
+	}
+	if !strings.Contains(prompts[0], "evid_ledger") || !strings.Contains(prompts[0], "Agnes: Tomorrow, I promise.") {
+		t.Errorf("expected the prompt to carry reveals and the transcript, got %q", prompts[0])
+	}
+
+	// The next compaction builds on the previous summary
+	addTurns(10)
+	if err := compactHistory(context.Background(), agentObj, 100, 4); err != nil {
+		t.Fatalf("compactHistory returned error: %v", err)
+	}
+	if len(store.summaries) != 2 || store.summaries[1].throughIndex != 36 {
+		t.Fatalf("expected a second summary through index 36, got %+v", store.summaries)
+	}
+	if !strings.Contains(prompts[1], "Summary 1") || strings.Contains(agentObj.History[0].Content, "Summary 1") {
+		t.Error("expected the previous summary to be folded into the new one and replaced")
+	}
+}
+
+func TestCompactHistoryReleasesLockWhileSummarizing(t *testing.T) {
+	store := &memoryConversationStore{indexes: map[int]string{}}
+	prevStore := conversations
+	conversations = store
+	defer func() { conversations = prevStore }()
+
+	agentObj := &agent.Agent{
+		ID:                  "agent-1",
+		CharacterName:       "Agnes",
+		History:             []llm.Message{llm.NewMessage(llm.RoleModel, "You are Agnes.")},
+		RevealedEvidenceIDs: map[string]bool{},
+		RevealedLocationIDs: map[string]bool{},
+		NextIndex:           1,
+	}
+	for i := 0; i < 10; i++ {
+		agentObj.History = append(agentObj.History,
+			llm.NewMessage(llm.RoleUser, "Where were you that night?"),
+			llm.NewMessage(llm.RoleModel, `{"reply": "At home, alone."}`))
+		agentObj.NextIndex += 2
+	}
+
+	// A turn lands while the summary is being written; it could not take the lock
+	// if compaction still held it
+	prevGen := llm.Default()
+	llm.SetDefault(&llm.Fake{Respond: func(history []llm.Message, opts llm.Options) (string, error) {
+		agentObj.Lock()
+		agentObj.History = append(agentObj.History,
+			llm.NewMessage(llm.RoleUser, "Can anyone confirm that?"),
+			llm.NewMessage(llm.RoleModel, `{"reply": "No."}`))
+		agentObj.NextIndex += 2
+		agentObj.Unlock()
+		return "- Agnes says she was at home.", nil
+	}})
+	defer llm.SetDefault(prevGen)
+
+	if err := compactHistory(context.Background(), agentObj, 100, 4); err != nil {
+		t.Fatalf("compactHistory returned error: %v", err)
+	}
+	if len(store.summaries) != 0 || agentObj.Summary != "" || len(agentObj.History) != 23 {
+		t.Errorf("expected a summary overtaken by a turn to be dropped, got %d stored and %d history entries",
+			len(store.summaries), len(agentObj.History))
+	}
+}
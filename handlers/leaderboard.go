@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"agent/db"
+	dbModels "agent/db/models"
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	defaultLeaderboardLimit = 10
+	maxLeaderboardLimit     = 100
+)
+
+// SubmissionResponse is a stored score submission
+type SubmissionResponse struct {
+	ID                      string         `json:"id"`
+	SessionID               string         `json:"session_id"`
+	PlayerID                string         `json:"player_id"`
+	StoryID                 string         `json:"story_id"`
+	Theory                  string         `json:"theory,omitempty"`
+	Score                   int            `json:"score"`
+	Reason                  string         `json:"reason,omitempty"`
+	CulpritCorrect          bool           `json:"culprit_correct"`
+	Breakdown               ScoreBreakdown `json:"breakdown"`
+	AccusedCulpritIDs       []string       `json:"accused_culprit_ids,omitempty"`
+	CitedEvidenceIDs        []string       `json:"cited_evidence_ids,omitempty"`
+	DiscoveredEvidenceCount int            `json:"discovered_evidence_count"`
+	MessageCount            int            `json:"message_count"`
+	TimeToSolveSeconds      int64          `json:"time_to_solve_seconds"`
+	SubmittedAt             time.Time      `json:"submitted_at"`
+}
+
+// LeaderboardEntry is a player's best submission and its rank on the leaderboard
+type LeaderboardEntry struct {
+	Rank int `json:"rank"`
+	SubmissionResponse
+}
+
+type LeaderboardResponse struct {
+	StoryID string             `json:"story_id"`
+	Entries []LeaderboardEntry `json:"entries"`
+}
+
+type SubmissionHistoryResponse struct {
+	PlayerID    string               `json:"player_id"`
+	Submissions []SubmissionResponse `json:"submissions"`
+}
+
+// newSubmissionResponse converts a submission document into its API shape
+func newSubmissionResponse(s dbModels.ScoreSubmissionDocument) SubmissionResponse {
+	return SubmissionResponse{
+		ID:             s.ID.Hex(),
+		SessionID:      s.SessionID.Hex(),
+		PlayerID:       s.PlayerID,
+		StoryID:        s.StoryID.Hex(),
+		Theory:         s.Theory,
+		Score:          s.Score,
+		Reason:         s.Reason,
+		CulpritCorrect: s.CulpritCorrect,
+		Breakdown: ScoreBreakdown{
+			Culprit:       s.Breakdown.Culprit,
+			Motive:        s.Breakdown.Motive,
+			Sequence:      s.Breakdown.Sequence,
+			Evidence:      s.Breakdown.Evidence,
+			Relationships: s.Breakdown.Relationships,
+		},
+		AccusedCulpritIDs:       s.AccusedCulpritIDs,
+		CitedEvidenceIDs:        s.CitedEvidenceIDs,
+		DiscoveredEvidenceCount: s.DiscoveredEvidenceCount,
+		MessageCount:            s.MessageCount,
+		TimeToSolveSeconds:      int64(s.TimeToSolve.Seconds()),
+		SubmittedAt:             s.SubmittedAt,
+	}
+}
+
+// LeaderboardHandler ranks each player's best submission for a story. Ties on
+// score go to the player who used fewer messages, then to the faster solve.
+func LeaderboardHandler(w http.ResponseWriter, r *http.Request) {
+	storyID := r.URL.Query().Get("story_id")
+	storyObjID, err := primitive.ObjectIDFromHex(storyID)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid story ID")
+		return
+	}
+
+	limit, ok := parseListLimit(r, defaultLeaderboardLimit)
+	if !ok {
+		writeJSONError(w, http.StatusBadRequest, "limit must be a positive integer")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	submissions, err := db.GetStoryLeaderboard(ctx, storyObjID, limit)
+	if err != nil {
+		log.Printf("[LEADERBOARD_ERROR] Failed to load leaderboard for story %s: %v", storyID, err)
+		writeJSONError(w, http.StatusInternalServerError, "Failed to load leaderboard")
+		return
+	}
+
+	resp := LeaderboardResponse{StoryID: storyID, Entries: make([]LeaderboardEntry, 0, len(submissions))}
+	for i, s := range submissions {
+		entry := LeaderboardEntry{Rank: i + 1, SubmissionResponse: newSubmissionResponse(s)}
+		// Other players' theories would spoil the case
+		entry.Theory = ""
+		entry.Reason = ""
+		entry.AccusedCulpritIDs = nil
+		entry.CitedEvidenceIDs = nil
+		resp.Entries = append(resp.Entries, entry)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// SubmissionHistoryHandler lists a player's submissions, newest first,
+// optionally for a single story
+func SubmissionHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	playerID, ok := resolvePlayerID(w, r, r.URL.Query().Get("player_id"))
+	if !ok {
+		return
+	}
+	if playerID == "" {
+		writeJSONError(w, http.StatusBadRequest, "player_id is required")
+		return
+	}
+
+	var storyObjID primitive.ObjectID
+	if storyID := r.URL.Query().Get("story_id"); storyID != "" {
+		var err error
+		if storyObjID, err = primitive.ObjectIDFromHex(storyID); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "Invalid story ID")
+			return
+		}
+	}
+
+	limit, ok := parseListLimit(r, defaultLeaderboardLimit)
+	if !ok {
+		writeJSONError(w, http.StatusBadRequest, "limit must be a positive integer")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	submissions, err := db.GetPlayerSubmissions(ctx, playerID, storyObjID, limit)
+	if err != nil {
+		log.Printf("[SUBMISSIONS_ERROR] Failed to load submissions for player %s: %v", playerID, err)
+		writeJSONError(w, http.StatusInternalServerError, "Failed to load submissions")
+		return
+	}
+
+	resp := SubmissionHistoryResponse{PlayerID: playerID, Submissions: make([]SubmissionResponse, 0, len(submissions))}
+	for _, s := range submissions {
+		resp.Submissions = append(resp.Submissions, newSubmissionResponse(s))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// parseListLimit reads the "limit" query parameter, capped at maxLeaderboardLimit
+func parseListLimit(r *http.Request, defaultLimit int) (int, bool) {
+	raw := r.URL.Query().Get("limit")
+	if raw == "" {
+		return defaultLimit, true
+	}
+	limit, err := strconv.Atoi(raw)
+	if err != nil || limit <= 0 {
+		return 0, false
+	}
+	return min(limit, maxLeaderboardLimit), true
+}
@@ -1,25 +1,26 @@
 package handlers
 
 import (
-	"agent/config"
+	"agent/llm"
+	"agent/metrics"
 	"agent/models"
 	"context"
 	"encoding/json"
 	"fmt"
 	"log"
-
-	"google.golang.org/genai"
 )
 
 // LocationRevealDetector analyzes dialogue to detect location reveals
 type LocationRevealDetector struct {
 	locations []models.Location
+	generator llm.Generator
 }
 
 // NewLocationRevealDetector creates a new detector with all story locations
 func NewLocationRevealDetector(story *models.Story) *LocationRevealDetector {
 	return &LocationRevealDetector{
 		locations: story.Story.Locations,
+		generator: llm.Default(),
 	}
 }
 
@@ -59,23 +60,8 @@ func (d *LocationRevealDetector) DetectRevealedLocations(ctx context.Context, di
 		dialogue,
 	)
 
-	// Initialize Gemini client
-	client, err := genai.NewClient(ctx, &genai.ClientConfig{
-		APIKey: config.GetGeminiAPIKey(),
-	})
-	if err != nil {
-		log.Printf("[LOCATION_DETECTOR_ERROR] Failed to create Gemini client: %v", err)
-		return []string{}
-	}
-
 	// Generate response
-	genConfig := &genai.GenerateContentConfig{
-		ResponseMIMEType: "application/json",
-	}
-
-	resp, err := client.Models.GenerateContent(ctx, config.GetGeminiModel(),
-		[]*genai.Content{genai.NewContentFromText(prompt, genai.RoleUser)},
-		genConfig)
+	responseText, err := d.generator.Generate(ctx, prompt, llm.Options{JSON: true, Purpose: llm.PurposeRevealDetection})
 	if err != nil {
 		log.Printf("[LOCATION_DETECTOR_ERROR] Failed to generate response: %v", err)
 		return []string{}
@@ -83,9 +69,9 @@ func (d *LocationRevealDetector) DetectRevealedLocations(ctx context.Context, di
 
 	// Parse the JSON response
 	var revealedLocationIDs []string
-	responseText := resp.Text()
 	if err := json.Unmarshal([]byte(responseText), &revealedLocationIDs); err != nil {
 		log.Printf("[LOCATION_DETECTOR_ERROR] Failed to parse LLM response: %v. Response was: %s", err, responseText)
+		metrics.JSONParseFailed(llm.PurposeRevealDetection)
 		return []string{}
 	}
 
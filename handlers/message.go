@@ -0,0 +1,373 @@
+package handlers
+
+import (
+	"agent/agent"
+	"agent/auth"
+	"agent/db"
+	"agent/events"
+	"agent/llm"
+	"agent/metrics"
+	"agent/models"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+type MessageRequest struct {
+	AgentID              string   `json:"agent_id"`
+	Message              string   `json:"message"`
+	PresentedEvidenceIDs []string `json:"presented_evidence_ids,omitempty"`
+	LocationID           string   `json:"location_id,omitempty"`
+}
+
+type MessageResponse struct {
+	Reply             string         `json:"reply"`
+	RevealedEvidences []string       `json:"revealed_evidences"`
+	RevealedLocations []string       `json:"revealed_locations"`
+	Standing          agent.Standing `json:"standing"`
+}
+
+// RevealEvent is published to the session when a character reveals evidence or locations
+type RevealEvent struct {
+	AgentID     string   `json:"agent_id"`
+	CharacterID string   `json:"character_id"`
+	IDs         []string `json:"ids"`
+}
+
+// agentReply is the JSON shape the character prompt asks the model to answer in
+type agentReply struct {
+	Reply             string   `json:"reply"`
+	RevealedEvidences []string `json:"revealed_evidences"`
+}
+
+func MessageHandler(w http.ResponseWriter, r *http.Request) {
+	req, agentObj, ok := decodeMessageRequest(w, r)
+	if !ok {
+		return
+	}
+	defer agentObj.Unpin()
+
+	// Generation stops when the client goes away; a reply that completes is
+	// still saved, as streamAgentTurn detaches from ctx once it has one
+	ctx, cancel := context.WithTimeout(r.Context(), 60*time.Second)
+	defer cancel()
+
+	story, err := fetchStoryFrom(ctx, agentObj.StoryCollection, agentObj.StoryID)
+	if err != nil {
+		writeJSONError(w, http.StatusNotFound, "Story not found")
+		return
+	}
+
+	resp, err := runAgentTurn(ctx, agentObj, story, req)
+	if errors.Is(err, db.ErrDuplicateIndex) {
+		log.Printf("[MESSAGE_ERROR] Conversation for agent %s diverged from the database: %v", req.AgentID, err)
+		writeJSONError(w, http.StatusConflict, "Conversation was modified concurrently, please retry")
+		return
+	}
+	if err != nil {
+		log.Printf("[MESSAGE_ERROR] Failed to generate reply for agent %s: %v", req.AgentID, err)
+		writeJSONError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to generate reply: %v", err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// decodeMessageRequest validates a message request and looks up its agent,
+// writing the error response and returning false if either fails. On success
+// the agent is pinned in the registry and the caller must Unpin it.
+func decodeMessageRequest(w http.ResponseWriter, r *http.Request) (MessageRequest, *agent.Agent, bool) {
+	var req MessageRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return req, nil, false
+	}
+
+	if strings.TrimSpace(req.Message) == "" {
+		writeJSONError(w, http.StatusBadRequest, "message is required")
+		return req, nil, false
+	}
+
+	agentObj, ok := agent.AcquireAgent(req.AgentID)
+	if !ok {
+		writeJSONError(w, http.StatusNotFound, "Agent not found")
+		return req, nil, false
+	}
+	if !auth.Owns(r.Context(), agentObj.PlayerID) {
+		agentObj.Unpin()
+		writeJSONError(w, http.StatusForbidden, "Agent belongs to another player")
+		return req, nil, false
+	}
+	return req, agentObj, true
+}
+
+// runAgentTurn sends one player message to the agent and records the result.
+// The agent lock is held for the whole turn so concurrent messages to the
+// same agent are processed one at a time and never share conversation indexes.
+func runAgentTurn(ctx context.Context, agentObj *agent.Agent, story *models.Story, req MessageRequest) (*MessageResponse, error) {
+	return streamAgentTurn(ctx, agentObj, story, req, nil)
+}
+
+// streamAgentTurn is runAgentTurn with the reply text passed to onDelta as the
+// model generates it. Reveal detection and persistence still wait for the full
+// reply, so a stream that fails part way leaves no trace of the turn, and run
+// on a context detached from ctx, so a complete reply is saved even if ctx is
+// cancelled once it has been generated.
+func streamAgentTurn(ctx context.Context, agentObj *agent.Agent, story *models.Story, req MessageRequest, onDelta func(string)) (*MessageResponse, error) {
+	agentObj.Lock()
+	defer agentObj.Unlock()
+
+	// Classify the tone while the character composes its reply
+	toneCh := make(chan string, 1)
+	go func() {
+		toneCh <- NewToneClassifier().ClassifyTone(ctx, req.Message)
+	}()
+
+	// Standing changes from this turn only stick if the turn completes
+	standingBefore := agentObj.Standing()
+	presentedBefore := maps.Clone(agentObj.PresentedEvidenceIDs)
+	completed := false
+	defer func() {
+		if !completed {
+			agentObj.Reputation, agentObj.Intimidation = standingBefore.Reputation, standingBefore.Intimidation
+			agentObj.PresentedEvidenceIDs = presentedBefore
+		}
+	}()
+
+	req.PresentedEvidenceIDs = presentableEvidence(ctx, agentObj, story, req.PresentedEvidenceIDs)
+	agentObj.ApplyPresentedEvidence(findStoryEvidence(story, req.PresentedEvidenceIDs))
+
+	var heldEvidence []models.Evidence
+	if character := findCharacter(story, agentObj.CharacterID); character != nil {
+		heldEvidence = character.HoldsEvidence
+	}
+
+	fullMessage := buildUserMessage(req, story, withheldEvidence(agentObj, heldEvidence))
+	userContent := llm.NewMessage(llm.RoleUser, fullMessage)
+
+	history := append(slices.Clone(agentObj.History), userContent)
+	var modelText string
+	var err error
+	if onDelta != nil {
+		modelText, err = llm.ChatStream(ctx, llm.Default(), history, llm.Options{JSON: true, Purpose: llm.PurposeChat}, newReplyExtractor(onDelta).Write)
+	} else {
+		modelText, err = llm.Default().Chat(ctx, history, llm.Options{JSON: true, Purpose: llm.PurposeChat})
+	}
+	tone := <-toneCh
+	if err != nil {
+		return nil, err
+	}
+	agentObj.ApplyTone(tone)
+
+	// The reply is complete: finish the turn even if the caller's context ends now
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 30*time.Second)
+	defer cancel()
+
+	var parsed agentReply
+	if err := json.Unmarshal([]byte(modelText), &parsed); err != nil || strings.TrimSpace(parsed.Reply) == "" {
+		log.Printf("[MESSAGE_WARNING] Model reply for agent %s was not valid JSON, using raw text", agentObj.ID)
+		metrics.JSONParseFailed(llm.PurposeChat)
+		parsed = agentReply{Reply: modelText}
+	}
+
+	// Only keep evidence the character actually holds and is willing to give up
+	revealedEvidences := []string{}
+	for _, id := range parsed.RevealedEvidences {
+		if !slices.Contains(agentObj.HoldsEvidenceIDs, id) {
+			log.Printf("[MESSAGE_WARNING] Agent %s claimed evidence it does not hold: %s", agentObj.ID, id)
+			continue
+		}
+		if i := slices.IndexFunc(heldEvidence, func(e models.Evidence) bool { return e.ID == id }); i >= 0 && !agentObj.CanReveal(heldEvidence[i]) {
+			log.Printf("[EVIDENCE_GATE] Agent %s tried to reveal %s below its thresholds (standing %+v)", agentObj.ID, id, agentObj.Standing())
+			continue
+		}
+		revealedEvidences = append(revealedEvidences, id)
+	}
+
+	// Only keep locations the character actually knows
+	revealedLocations := []string{}
+	detector := NewLocationRevealDetector(story)
+	for _, id := range detector.DetectRevealedLocations(ctx, parsed.Reply) {
+		if slices.Contains(agentObj.KnowsLocationIDs, id) {
+			revealedLocations = append(revealedLocations, id)
+		}
+	}
+
+	// Persist the turn before applying it, so a turn that can't be stored fails
+	// instead of diverging from the stored transcript
+	if err := saveTurn(ctx, agentObj, fullMessage, modelText, parsed.Reply, revealedEvidences, revealedLocations); err != nil {
+		return nil, err
+	}
+
+	agentObj.History = append(agentObj.History, userContent, llm.NewMessage(llm.RoleModel, modelText))
+	for _, id := range revealedEvidences {
+		agentObj.RevealedEvidenceIDs[id] = true
+	}
+	for _, id := range revealedLocations {
+		agentObj.RevealedLocationIDs[id] = true
+	}
+	if len(revealedEvidences) > 0 || len(revealedLocations) > 0 {
+		if err := conversations.UpdateReveals(ctx, agentObj.ID, agentObj.RevealedEvidenceIDs, agentObj.RevealedLocationIDs); err != nil {
+			log.Printf("[MESSAGE_SAVE_ERROR] Failed to update reveals for agent %s: %v", agentObj.ID, err)
+		}
+	}
+	if err := conversations.UpdateStanding(ctx, agentObj.ID, agentObj.Reputation, agentObj.Intimidation, agentObj.PresentedEvidenceIDs); err != nil {
+		log.Printf("[MESSAGE_SAVE_ERROR] Failed to update standing for agent %s: %v", agentObj.ID, err)
+	}
+	if agentObj.SessionID != "" {
+		progress := db.SessionProgress{
+			EvidenceIDs: revealedEvidences,
+			LocationIDs: revealedLocations,
+			Messages:    1,
+		}
+		if err := conversations.RecordSessionProgress(ctx, agentObj.SessionID, progress); err != nil {
+			log.Printf("[MESSAGE_SAVE_ERROR] Failed to update session %s: %v", agentObj.SessionID, err)
+		}
+		publishReveals(agentObj, revealedEvidences, revealedLocations)
+	}
+	scheduleCompaction(agentObj)
+
+	completed = true
+	return &MessageResponse{
+		Reply:             parsed.Reply,
+		RevealedEvidences: revealedEvidences,
+		RevealedLocations: revealedLocations,
+		Standing:          agentObj.Standing(),
+	}, nil
+}
+
+// saveTurn stores the player's message and the reply under the agent's next two
+// conversation indexes. On failure nothing of the turn is kept, so History and the
+// stored indexes stay in step: the indexes are released, and a user message stored
+// without its reply is deleted again. If that isn't possible, or another writer
+// already holds the indexes, the agent is dropped from the registry so the next
+// request reloads it from the database. The caller must hold the agent lock.
+func saveTurn(ctx context.Context, agentObj *agent.Agent, fullMessage, modelText, reply string, revealedEvidences, revealedLocations []string) error {
+	userIndex := agentObj.ReserveIndexes(2)
+
+	stale := false
+	err := conversations.SaveMessage(ctx, agentObj.ID, fullMessage,
+		extractClientContent(fullMessage, "user"), "user", userIndex, nil, nil)
+	if err != nil {
+		err = fmt.Errorf("saving user message at index %d: %w", userIndex, err)
+	} else if err = conversations.SaveMessage(ctx, agentObj.ID, modelText,
+		reply, "model", userIndex+1, revealedEvidences, revealedLocations); err != nil {
+		err = fmt.Errorf("saving model message at index %d: %w", userIndex+1, err)
+		if delErr := conversations.DeleteMessage(ctx, agentObj.ID, userIndex); delErr != nil {
+			log.Printf("[MESSAGE_SAVE_ERROR] Failed to remove user message %d of agent %s: %v", userIndex, agentObj.ID, delErr)
+			stale = true
+		}
+	}
+	if err == nil {
+		return nil
+	}
+
+	agentObj.NextIndex = userIndex
+	if stale || errors.Is(err, db.ErrDuplicateIndex) {
+		log.Printf("[MESSAGE_SAVE_ERROR] Agent %s no longer matches its stored conversation, reloading it on the next request", agentObj.ID)
+		agent.DeleteAgent(agentObj.ID)
+	}
+	return err
+}
+
+// publishReveals tells the agent's session which evidence and locations the turn revealed
+func publishReveals(agentObj *agent.Agent, evidenceIDs, locationIDs []string) {
+	if len(evidenceIDs) > 0 {
+		events.Publish(agentObj.SessionID, events.TypeEvidenceRevealed, RevealEvent{
+			AgentID:     agentObj.ID,
+			CharacterID: agentObj.CharacterID,
+			IDs:         evidenceIDs,
+		})
+	}
+	if len(locationIDs) > 0 {
+		events.Publish(agentObj.SessionID, events.TypeLocationRevealed, RevealEvent{
+			AgentID:     agentObj.ID,
+			CharacterID: agentObj.CharacterID,
+			IDs:         locationIDs,
+		})
+	}
+}
+
+// presentableEvidence returns the requested evidence IDs the player may show the
+// character, in request order: those the agent's session has discovered, or for
+// an agent without a session, any evidence in the story
+func presentableEvidence(ctx context.Context, agentObj *agent.Agent, story *models.Story, ids []string) []string {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	var allowed func(id string) bool
+	if agentObj.SessionID == "" {
+		inStory := storyEvidenceIDs(story)
+		allowed = func(id string) bool { return inStory[id] }
+	} else {
+		discovered, err := conversations.DiscoveredEvidence(ctx, agentObj.SessionID)
+		if err != nil {
+			log.Printf("[MESSAGE_WARNING] Failed to load discovered evidence for session %s: %v", agentObj.SessionID, err)
+			return nil
+		}
+		allowed = func(id string) bool { return slices.Contains(discovered, id) }
+	}
+
+	found := []string{}
+	for _, id := range ids {
+		if allowed(id) && !slices.Contains(found, id) {
+			found = append(found, id)
+		}
+	}
+	return found
+}
+
+// withheldEvidence returns the unrevealed evidence whose standing thresholds are not yet met.
+// The caller must hold the agent lock.
+func withheldEvidence(agentObj *agent.Agent, held []models.Evidence) []string {
+	withheld := []string{}
+	for _, e := range held {
+		if !agentObj.RevealedEvidenceIDs[e.ID] && !agentObj.CanReveal(e) {
+			withheld = append(withheld, e.ID)
+		}
+	}
+	return withheld
+}
+
+// buildUserMessage wraps the player's text with location, withheld evidence and
+// presented evidence context. The tags match the patterns stripped by extractClientContent.
+func buildUserMessage(req MessageRequest, story *models.Story, withheld []string) string {
+	var sb strings.Builder
+
+	if len(withheld) > 0 {
+		sb.WriteString(fmt.Sprintf("[WITHHELD EVIDENCE: you do not trust or fear the investigator enough yet to hand over %s]\n\n",
+			strings.Join(withheld, ", ")))
+	}
+
+	if req.LocationID != "" {
+		for _, loc := range story.Story.Locations {
+			if loc.ID == req.LocationID {
+				sb.WriteString(fmt.Sprintf("[CURRENT LOCATION: %s - %s]\n\n", loc.LocationName, loc.VisualDescription))
+				break
+			}
+		}
+	}
+
+	sb.WriteString(req.Message)
+
+	if len(req.PresentedEvidenceIDs) > 0 {
+		evidence := findStoryEvidence(story, req.PresentedEvidenceIDs)
+		if len(evidence) > 0 {
+			sb.WriteString("\n\n[USER IS PRESENTING THE FOLLOWING EVIDENCE TO YOU]:\n")
+			for _, e := range evidence {
+				sb.WriteString(fmt.Sprintf("- [%s] %s: %s (Visual: %s)\n", e.ID, e.Title, e.Description, e.VisualDescription))
+			}
+		}
+	}
+
+	return sb.String()
+}
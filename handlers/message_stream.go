@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// MessageDelta is the payload of a "delta" event: the next piece of the reply text
+type MessageDelta struct {
+	Text string `json:"text"`
+}
+
+// MessageStreamHandler is the streaming variant of MessageHandler. The reply is
+// sent as Server-Sent Events: "delta" events carry reply text as it is
+// generated, then a single "done" event carries the full MessageResponse once
+// reveal detection has run and the turn has been saved. If generation fails an
+// "error" event is sent instead and nothing is persisted.
+func MessageStreamHandler(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeJSONError(w, http.StatusInternalServerError, "Streaming not supported")
+		return
+	}
+
+	req, agentObj, ok := decodeMessageRequest(w, r)
+	if !ok {
+		return
+	}
+	defer agentObj.Unpin()
+
+	// Generation stops when the client goes away; streamAgentTurn detaches from
+	// the request once the reply is complete so a finished turn is still saved
+	ctx, cancel := context.WithTimeout(r.Context(), 60*time.Second)
+	defer cancel()
+
+	story, err := fetchStoryFrom(ctx, agentObj.StoryCollection, agentObj.StoryID)
+	if err != nil {
+		writeJSONError(w, http.StatusNotFound, "Story not found")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no") // Stop nginx from buffering the stream
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	send := func(event string, data any) {
+		payload, err := json.Marshal(data)
+		if err != nil {
+			log.Printf("[STREAM_ERROR] Failed to encode %s event: %v", event, err)
+			return
+		}
+		fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, payload)
+		flusher.Flush()
+	}
+
+	resp, err := streamAgentTurn(ctx, agentObj, story, req, func(delta string) {
+		send("delta", MessageDelta{Text: delta})
+	})
+	if err != nil {
+		log.Printf("[MESSAGE_ERROR] Failed to stream reply for agent %s: %v", req.AgentID, err)
+		send("error", map[string]string{"error": fmt.Sprintf("Failed to generate reply: %v", err)})
+		return
+	}
+
+	send("done", resp)
+}
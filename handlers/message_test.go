@@ -0,0 +1,457 @@
+package handlers
+
+import (
+	"agent/agent"
+	"agent/db"
+	"agent/llm"
+	"agent/models"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// memoryConversationStore records persisted turns in memory
+type memoryConversationStore struct {
+	mu         sync.Mutex
+	indexes    map[int]string
+	reveals    int
+	summaries  []savedSummary
+	discovered []string
+	failRole   string // SaveMessage fails for messages with this role
+}
+
+func (m *memoryConversationStore) SaveMessage(ctx context.Context, agentID, fullContent, clientContent, role string, index int, revealedEvidences, revealedLocations []string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if role == m.failRole {
+		return fmt.Errorf("write to %s message failed", role)
+	}
+	if _, dup := m.indexes[index]; dup {
+		return db.ErrDuplicateIndex
+	}
+	m.indexes[index] = role
+	return nil
+}
+
+func (m *memoryConversationStore) DeleteMessage(ctx context.Context, agentID string, index int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.indexes, index)
+	return nil
+}
+
+func (m *memoryConversationStore) UpdateReveals(ctx context.Context, agentID string, revealedEvidenceIDs, revealedLocationIDs map[string]bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	// Touch the maps the way the Mongo encoder would
+	m.reveals += len(revealedEvidenceIDs) + len(revealedLocationIDs)
+	return nil
+}
+
+func (m *memoryConversationStore) UpdateStanding(ctx context.Context, agentID string, reputation, intimidation int, presentedEvidenceIDs map[string]bool) error {
+	return nil
+}
+
+func (m *memoryConversationStore) DiscoveredEvidence(ctx context.Context, sessionID string) ([]string, error) {
+	return m.discovered, nil
+}
+
+func (m *memoryConversationStore) RecordSessionProgress(ctx context.Context, sessionID string, progress db.SessionProgress) error {
+	return nil
+}
+
+func (m *memoryConversationStore) SaveSummary(ctx context.Context, agentID, summary string, throughIndex int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.summaries = append(m.summaries, savedSummary{summary, throughIndex})
+	return nil
+}
+
+type savedSummary struct {
+	summary      string
+	throughIndex int
+}
+
+func TestRunAgentTurnSerializesConcurrentMessages(t *testing.T) {
+	store := &memoryConversationStore{indexes: map[int]string{}}
+	prevStore := conversations
+	conversations = store
+	defer func() { conversations = prevStore }()
+
+	prevGen := llm.Default()
+	llm.SetDefault(&llm.Fake{Respond: func(history []llm.Message, opts llm.Options) (string, error) {
+		if strings.Contains(history[0].Content, "location reveal detector") {
+			return `["loc_1"]`, nil
+		}
+		if strings.Contains(history[0].Content, "tone classifier") {
+			return `{"tone": "neutral"}`, nil
+		}
+		return fmt.Sprintf(`{"reply": "Turn %d. Leave me alone.", "revealed_evidences": ["evid_1"]}`, len(history)), nil
+	}})
+	defer llm.SetDefault(prevGen)
+
+	story := &models.Story{Story: models.StoryContent{
+		Locations: []models.Location{{ID: "loc_1", LocationName: "Docks"}},
+	}}
+	agentObj := &agent.Agent{
+		ID:                  "agent-1",
+		History:             []llm.Message{llm.NewMessage(llm.RoleModel, "You are Agnes.")},
+		HoldsEvidenceIDs:    []string{"evid_1"},
+		KnowsLocationIDs:    []string{"loc_1"},
+		RevealedEvidenceIDs: map[string]bool{},
+		RevealedLocationIDs: map[string]bool{},
+		NextIndex:           1,
+	}
+
+	const messages = 20
+	var wg sync.WaitGroup
+	errs := make(chan error, messages)
+	for i := 0; i < messages; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, err := runAgentTurn(context.Background(), agentObj, story, MessageRequest{
+				AgentID: agentObj.ID,
+				Message: fmt.Sprintf("Question %d", i),
+			})
+			errs <- err
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("runAgentTurn returned error: %v", err)
+		}
+	}
+
+	if len(agentObj.History) != 1+2*messages {
+		t.Errorf("expected %d history entries, got %d", 1+2*messages, len(agentObj.History))
+	}
+	if agentObj.NextIndex != 1+2*messages {
+		t.Errorf("expected NextIndex %d, got %d", 1+2*messages, agentObj.NextIndex)
+	}
+	for i := 1; i <= 2*messages; i++ {
+		want := "user"
+		if i%2 == 0 {
+			want = "model"
+		}
+		if store.indexes[i] != want {
+			t.Errorf("index %d: expected %s message, got %q", i, want, store.indexes[i])
+		}
+	}
+
+	// Each turn must see the previous turns in its history
+	for i := 1; i < len(agentObj.History); i += 2 {
+		if agentObj.History[i].Role != llm.RoleUser || agentObj.History[i+1].Role != llm.RoleModel {
+			t.Fatalf("history out of order at %d", i)
+		}
+		if want := fmt.Sprintf("Turn %d.", i+1); !strings.Contains(agentObj.History[i+1].Content, want) {
+			t.Errorf("expected reply at %d to contain %q, got %q", i+1, want, agentObj.History[i+1].Content)
+		}
+	}
+
+	if !agentObj.RevealedEvidenceIDs["evid_1"] || !agentObj.RevealedLocationIDs["loc_1"] {
+		t.Error("expected reveals to be recorded on the agent")
+	}
+}
+
+func TestRunAgentTurnLeavesNoTraceWhenSavingFails(t *testing.T) {
+	// Another replica already stored a message at index 1
+	store := &memoryConversationStore{indexes: map[int]string{1: "user"}}
+	prevStore := conversations
+	conversations = store
+	defer func() { conversations = prevStore }()
+
+	prevGen := llm.Default()
+	llm.SetDefault(&llm.Fake{Respond: func(history []llm.Message, opts llm.Options) (string, error) {
+		switch {
+		case strings.Contains(history[0].Content, "location reveal detector"):
+			return `[]`, nil
+		case strings.Contains(history[0].Content, "tone classifier"):
+			return `{"tone": "neutral"}`, nil
+		}
+		return `{"reply": "Hello.", "revealed_evidences": []}`, nil
+	}})
+	defer llm.SetDefault(prevGen)
+
+	agentObj := &agent.Agent{
+		ID:                  "agent-1",
+		History:             []llm.Message{llm.NewMessage(llm.RoleModel, "You are Agnes.")},
+		RevealedEvidenceIDs: map[string]bool{},
+		RevealedLocationIDs: map[string]bool{},
+		NextIndex:           1,
+	}
+
+	_, err := runAgentTurn(context.Background(), agentObj, &models.Story{}, MessageRequest{Message: "Hi"})
+	if !errors.Is(err, db.ErrDuplicateIndex) {
+		t.Fatalf("expected ErrDuplicateIndex, got %v", err)
+	}
+	if len(agentObj.History) != 1 || agentObj.NextIndex != 1 {
+		t.Errorf("expected the failed turn to leave history and indexes untouched, got %d entries and next index %d",
+			len(agentObj.History), agentObj.NextIndex)
+	}
+
+	// A reply that can't be stored takes its already stored user message with it
+	delete(store.indexes, 1)
+	store.failRole = "model"
+	if _, err := runAgentTurn(context.Background(), agentObj, &models.Story{}, MessageRequest{Message: "Hi"}); err == nil {
+		t.Fatal("expected an error when the reply can't be saved")
+	}
+	if len(store.indexes) != 0 || len(agentObj.History) != 1 || agentObj.NextIndex != 1 {
+		t.Errorf("expected no trace of the failed turn, got stored %v, %d history entries and next index %d",
+			store.indexes, len(agentObj.History), agentObj.NextIndex)
+	}
+
+	store.failRole = ""
+	if _, err := runAgentTurn(context.Background(), agentObj, &models.Story{}, MessageRequest{Message: "Hi"}); err != nil {
+		t.Fatalf("runAgentTurn returned error: %v", err)
+	}
+	if store.indexes[1] != "user" || store.indexes[2] != "model" || len(agentObj.History) != 3 || agentObj.NextIndex != 3 {
+		t.Errorf("expected the retried turn at indexes 1 and 2, got stored %v and next index %d", store.indexes, agentObj.NextIndex)
+	}
+}
+
+func TestRunAgentTurnGatesEvidenceByStanding(t *testing.T) {
+	prevStore := conversations
+	conversations = &memoryConversationStore{indexes: map[int]string{}}
+	defer func() { conversations = prevStore }()
+
+	tone := agent.ToneNeutral
+	var lastMessage string
+	prevGen := llm.Default()
+	llm.SetDefault(&llm.Fake{Respond: func(history []llm.Message, opts llm.Options) (string, error) {
+		switch {
+		case strings.Contains(history[0].Content, "location reveal detector"):
+			return `[]`, nil
+		case strings.Contains(history[0].Content, "tone classifier"):
+			return fmt.Sprintf(`{"tone": %q}`, tone), nil
+		}
+		lastMessage = history[len(history)-1].Content
+		return `{"reply": "[hands over ledger] Fine, take it.", "revealed_evidences": ["evid_ledger"]}`, nil
+	}})
+	defer llm.SetDefault(prevGen)
+
+	story := &models.Story{Story: models.StoryContent{
+		Characters: []models.Character{{
+			ID: "char_1",
+			HoldsEvidence: []models.Evidence{
+				{ID: "evid_ledger", Title: "Ledger", MinReputation: 20, MinIntimidation: 10},
+			},
+		}},
+	}}
+	agentObj := &agent.Agent{
+		ID:                  "agent-1",
+		CharacterID:         "char_1",
+		HoldsEvidenceIDs:    []string{"evid_ledger"},
+		RevealedEvidenceIDs: map[string]bool{},
+		RevealedLocationIDs: map[string]bool{},
+		Reputation:          18,
+		Intimidation:        0,
+	}
+
+	// A threatening tone raises intimidation but costs reputation, so the gate stays shut
+	tone = agent.ToneThreatening
+	resp, err := runAgentTurn(context.Background(), agentObj, story, MessageRequest{Message: "Hand it over or else."})
+	if err != nil {
+		t.Fatalf("runAgentTurn returned error: %v", err)
+	}
+	if len(resp.RevealedEvidences) != 0 || agentObj.RevealedEvidenceIDs["evid_ledger"] {
+		t.Errorf("expected ledger to be withheld, got %v", resp.RevealedEvidences)
+	}
+	if resp.Standing != (agent.Standing{Reputation: 13, Intimidation: 10}) {
+		t.Errorf("unexpected standing %+v", resp.Standing)
+	}
+	if !strings.Contains(lastMessage, "[WITHHELD EVIDENCE:") || !strings.Contains(lastMessage, "evid_ledger") {
+		t.Errorf("expected the model to be told the ledger is withheld, got %q", lastMessage)
+	}
+
+	// Two friendly turns rebuild enough trust to meet both thresholds
+	tone = agent.ToneFriendly
+	runAgentTurn(context.Background(), agentObj, story, MessageRequest{Message: "Sorry, I know this is hard."})
+	resp, err = runAgentTurn(context.Background(), agentObj, story, MessageRequest{Message: "I'm on your side."})
+	if err != nil {
+		t.Fatalf("runAgentTurn returned error: %v", err)
+	}
+	if len(resp.RevealedEvidences) != 1 || resp.RevealedEvidences[0] != "evid_ledger" {
+		t.Errorf("expected ledger to be revealed once thresholds are met, got %v (standing %+v)", resp.RevealedEvidences, resp.Standing)
+	}
+}
+
+func TestRunAgentTurnCountsPresentedEvidenceOnce(t *testing.T) {
+	prevStore := conversations
+	conversations = &memoryConversationStore{
+		indexes:    map[int]string{},
+		discovered: []string{"evid_knife", "evid_letter"},
+	}
+	defer func() { conversations = prevStore }()
+
+	var lastMessage string
+	fail := false
+	prevGen := llm.Default()
+	llm.SetDefault(&llm.Fake{Respond: func(history []llm.Message, opts llm.Options) (string, error) {
+		switch {
+		case strings.Contains(history[0].Content, "location reveal detector"):
+			return `[]`, nil
+		case strings.Contains(history[0].Content, "tone classifier"):
+			return `{"tone": "neutral"}`, nil
+		case fail:
+			return "", fmt.Errorf("model unavailable")
+		}
+		lastMessage = history[len(history)-1].Content
+		return `{"reply": "That proves nothing.", "revealed_evidences": []}`, nil
+	}})
+	defer llm.SetDefault(prevGen)
+
+	story := &models.Story{Story: models.StoryContent{
+		Characters: []models.Character{
+			{ID: "char_1", HoldsEvidence: []models.Evidence{{ID: "evid_letter", Title: "Letter"}}},
+			{ID: "char_2", HoldsEvidence: []models.Evidence{
+				{ID: "evid_knife", Title: "Knife"},
+				{ID: "evid_ring", Title: "Ring", IsCritical: true},
+			}},
+		},
+	}}
+	agentObj := &agent.Agent{
+		ID:                  "agent-1",
+		SessionID:           "session-1",
+		CharacterID:         "char_1",
+		HoldsEvidenceIDs:    []string{"evid_letter"},
+		RevealedEvidenceIDs: map[string]bool{},
+		RevealedLocationIDs: map[string]bool{},
+	}
+
+	present := func(ids ...string) agent.Standing {
+		t.Helper()
+		resp, err := runAgentTurn(context.Background(), agentObj, story, MessageRequest{
+			Message:              "Look at this.",
+			PresentedEvidenceIDs: ids,
+		})
+		if err != nil {
+			t.Fatalf("runAgentTurn returned error: %v", err)
+		}
+		return resp.Standing
+	}
+
+	if got := present("evid_knife", "evid_knife"); got != (agent.Standing{Reputation: 2, Intimidation: 3}) {
+		t.Errorf("expected the knife to count once, got %+v", got)
+	}
+	if got := present("evid_knife"); got != (agent.Standing{Reputation: 2, Intimidation: 3}) {
+		t.Errorf("expected presenting the knife again not to raise standing, got %+v", got)
+	}
+	// The letter is the character's own evidence and the ring was never found
+	if got := present("evid_letter", "evid_ring"); got != (agent.Standing{Reputation: 2, Intimidation: 3}) {
+		t.Errorf("expected held and undiscovered evidence not to count, got %+v", got)
+	}
+	if strings.Contains(lastMessage, "evid_ring") {
+		t.Errorf("expected undiscovered evidence to be left out of the prompt, got %q", lastMessage)
+	}
+
+	// A turn that fails keeps neither the standing nor the evidence it presented
+	agentObj.HoldsEvidenceIDs = nil // Let the letter count, as it would for another character
+	fail = true
+	if _, err := runAgentTurn(context.Background(), agentObj, story, MessageRequest{
+		Message:              "Look at this.",
+		PresentedEvidenceIDs: []string{"evid_letter"},
+	}); err == nil {
+		t.Fatal("expected an error when generation fails")
+	}
+	if got := agentObj.Standing(); got != (agent.Standing{Reputation: 2, Intimidation: 3}) || agentObj.PresentedEvidenceIDs["evid_letter"] {
+		t.Errorf("expected the failed turn to be rolled back, got %+v", got)
+	}
+	fail = false
+	if got := present("evid_letter"); got != (agent.Standing{Reputation: 4, Intimidation: 6}) {
+		t.Errorf("expected evidence from a failed turn to count on the next one, got %+v", got)
+	}
+
+	// Agents spawned without a session may present any evidence in the story
+	agentObj.SessionID = ""
+	if got := present("evid_ring", "evid_unknown"); got != (agent.Standing{Reputation: 6, Intimidation: 16}) {
+		t.Errorf("expected story evidence to count without a session, got %+v", got)
+	}
+	if !strings.Contains(lastMessage, "evid_ring") || strings.Contains(lastMessage, "evid_unknown") {
+		t.Errorf("expected only story evidence in the prompt, got %q", lastMessage)
+	}
+}
+
+func TestStreamAgentTurnStreamsReplyBeforePersisting(t *testing.T) {
+	store := &memoryConversationStore{indexes: map[int]string{}}
+	prevStore := conversations
+	conversations = store
+	defer func() { conversations = prevStore }()
+
+	fail := false
+	prevGen := llm.Default()
+	llm.SetDefault(&llm.Fake{Respond: func(history []llm.Message, opts llm.Options) (string, error) {
+		switch {
+		case strings.Contains(history[0].Content, "location reveal detector"):
+			return `["loc_1"]`, nil
+		case strings.Contains(history[0].Content, "tone classifier"):
+			return `{"tone": "neutral"}`, nil
+		case fail:
+			return "", fmt.Errorf("stream dropped")
+		}
+		return `{"reply": "I was at the \"Docks\" — ask anyone.", "revealed_evidences": []}`, nil
+	}})
+	defer llm.SetDefault(prevGen)
+
+	story := &models.Story{Story: models.StoryContent{
+		Locations: []models.Location{{ID: "loc_1", LocationName: "Docks"}},
+	}}
+	agentObj := &agent.Agent{
+		ID:                  "agent-1",
+		History:             []llm.Message{llm.NewMessage(llm.RoleModel, "You are Agnes.")},
+		KnowsLocationIDs:    []string{"loc_1"},
+		RevealedEvidenceIDs: map[string]bool{},
+		RevealedLocationIDs: map[string]bool{},
+		NextIndex:           1,
+	}
+
+	var streamed strings.Builder
+	var savedDuringStream int
+	resp, err := streamAgentTurn(context.Background(), agentObj, story, MessageRequest{Message: "Where were you?"}, func(delta string) {
+		streamed.WriteString(delta)
+		store.mu.Lock()
+		savedDuringStream += len(store.indexes)
+		store.mu.Unlock()
+	})
+	if err != nil {
+		t.Fatalf("streamAgentTurn returned error: %v", err)
+	}
+
+	if streamed.String() != resp.Reply || resp.Reply != `I was at the "Docks" — ask anyone.` {
+		t.Errorf("expected streamed text to match reply, got %q and %q", streamed.String(), resp.Reply)
+	}
+	if savedDuringStream != 0 {
+		t.Error("expected nothing to be persisted while the reply was streaming")
+	}
+	if len(store.indexes) != 2 || len(resp.RevealedLocations) != 1 {
+		t.Errorf("expected the turn and its reveals to be saved, got %v and %v", store.indexes, resp.RevealedLocations)
+	}
+
+	fail = true
+	if _, err := streamAgentTurn(context.Background(), agentObj, story, MessageRequest{Message: "And then?"}, func(string) {}); err == nil {
+		t.Fatal("expected an error when the stream fails")
+	}
+	if len(store.indexes) != 2 || len(agentObj.History) != 3 {
+		t.Errorf("expected a failed stream to leave no trace, got %d saved messages and %d history entries", len(store.indexes), len(agentObj.History))
+	}
+
+	// A client that disconnects once the reply has been generated still gets its turn saved
+	fail = false
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if _, err := streamAgentTurn(ctx, agentObj, story, MessageRequest{Message: "Who saw you?"}, func(string) { cancel() }); err != nil {
+		t.Fatalf("streamAgentTurn returned error after the client went away: %v", err)
+	}
+	if len(store.indexes) != 4 {
+		t.Errorf("expected the completed turn to be saved, got %v", store.indexes)
+	}
+}
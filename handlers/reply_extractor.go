@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"unicode/utf8"
+)
+
+// replyKeyPattern matches the opening of the reply field at the end of the buffered prefix
+var replyKeyPattern = regexp.MustCompile(`"reply"\s*:\s*"$`)
+
+// Extractor states
+const (
+	extractStart = iota
+	extractSeekKey
+	extractInString
+	extractDone
+	extractRaw
+)
+
+// replyExtractor pulls the text of the "reply" field out of the character's JSON
+// answer while it is still streaming in, so players see the reply before the
+// object is complete. Escape sequences and multi-byte characters split across
+// chunks are held back until they can be decoded. If the model answers with
+// plain text instead of a JSON object, the text is passed through unchanged.
+type replyExtractor struct {
+	emit    func(string)
+	state   int
+	prefix  []byte // Buffered object text before the reply value
+	escape  []byte // Partial escape sequence inside the reply value
+	partial []byte // Incomplete UTF-8 sequence at the end of the last chunk
+}
+
+func newReplyExtractor(emit func(string)) *replyExtractor {
+	return &replyExtractor{emit: emit}
+}
+
+// Write consumes the next chunk of model output, emitting any reply text it completes
+func (x *replyExtractor) Write(chunk string) {
+	out := x.partial
+	x.partial = nil
+
+	for i := 0; i < len(chunk); i++ {
+		c := chunk[i]
+		switch x.state {
+		case extractStart:
+			switch {
+			case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			case c == '{':
+				x.state = extractSeekKey
+				x.prefix = append(x.prefix, c)
+			default:
+				x.state = extractRaw
+				out = append(out, c)
+			}
+		case extractSeekKey:
+			x.prefix = append(x.prefix, c)
+			if c == '"' && replyKeyPattern.Match(x.prefix) {
+				x.state = extractInString
+				x.prefix = nil
+			}
+		case extractInString:
+			switch {
+			case len(x.escape) > 0:
+				x.escape = append(x.escape, c)
+				if s, ok := decodeEscape(x.escape); ok {
+					out = append(out, s...)
+					x.escape = nil
+				}
+			case c == '\\':
+				x.escape = append(x.escape, c)
+			case c == '"':
+				x.state = extractDone
+			default:
+				out = append(out, c)
+			}
+		case extractRaw:
+			out = append(out, c)
+		}
+	}
+
+	if x.state != extractDone {
+		if n := incompleteRuneSuffix(out); n > 0 {
+			x.partial = append([]byte(nil), out[len(out)-n:]...)
+			out = out[:len(out)-n]
+		}
+	}
+	if len(out) > 0 {
+		x.emit(string(out))
+	}
+}
+
+// decodeEscape decodes a JSON escape sequence, reporting false while more bytes
+// are needed. A high surrogate waits for the low surrogate that should follow it.
+func decodeEscape(seq []byte) (string, bool) {
+	if len(seq) < 2 {
+		return "", false
+	}
+	if seq[1] == 'u' {
+		if len(seq) < 6 {
+			return "", false
+		}
+		code, err := strconv.ParseUint(string(seq[2:6]), 16, 16)
+		if err == nil && code >= 0xD800 && code < 0xDC00 && len(seq) < 12 {
+			// Keep waiting only while the bytes still look like a second \u escape
+			if (len(seq) < 7 || seq[6] == '\\') && (len(seq) < 8 || seq[7] == 'u') {
+				return "", false
+			}
+		}
+	}
+
+	var s string
+	if err := json.Unmarshal(append(append([]byte{'"'}, seq...), '"'), &s); err != nil {
+		return string(seq), true
+	}
+	return s, true
+}
+
+// incompleteRuneSuffix returns the length of a truncated UTF-8 sequence at the end of b
+func incompleteRuneSuffix(b []byte) int {
+	for i := len(b) - 1; i >= 0 && i >= len(b)-utf8.UTFMax+1; i-- {
+		if utf8.RuneStart(b[i]) {
+			if !utf8.FullRune(b[i:]) {
+				return len(b) - i
+			}
+			return 0
+		}
+	}
+	return 0
+}
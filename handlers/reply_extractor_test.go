@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReplyExtractorAcrossChunkBoundaries(t *testing.T) {
+	cases := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{
+			name:  "plain reply",
+			input: `{"reply": "Leave me alone.", "revealed_evidences": ["evid_1"]}`,
+			want:  "Leave me alone.",
+		},
+		{
+			name:  "reply after other fields",
+			input: `{"revealed_evidences": ["evid_1"], "reply" : "I saw \"him\" at the docks."}`,
+			want:  `I saw "him" at the docks.`,
+		},
+		{
+			name:  "escapes and multi-byte characters",
+			input: `{"reply": "Café 🔍\nNaïve — ✓"}`,
+			want:  "Café 🔍\nNaïve — ✓",
+		},
+		{
+			name:  "unicode escapes and surrogate pairs",
+			input: `{"reply": "clue \ud83d\udd0d \u00e9\t\\"}`,
+			want:  "clue 🔍 é\t\\",
+		},
+		{
+			name:  "plain text answer",
+			input: "I won't answer that.",
+			want:  "I won't answer that.",
+		},
+	}
+
+	for _, tc := range cases {
+		for size := 1; size <= len(tc.input); size++ {
+			var got strings.Builder
+			x := newReplyExtractor(func(delta string) {
+				if delta == "" {
+					t.Errorf("%s: empty delta emitted", tc.name)
+				}
+				got.WriteString(delta)
+			})
+			for i := 0; i < len(tc.input); i += size {
+				x.Write(tc.input[i:min(i+size, len(tc.input))])
+			}
+			if got.String() != tc.want {
+				t.Fatalf("%s (chunk size %d): expected %q, got %q", tc.name, size, tc.want, got.String())
+			}
+		}
+	}
+}
@@ -3,27 +3,46 @@ package handlers
 import (
 	"agent/config"
 	"agent/db"
+	dbModels "agent/db/models"
+	"agent/events"
+	"agent/llm"
+	"agent/metrics"
 	"agent/models"
 	"context"
 	"encoding/json"
 	"fmt"
+	"log"
+	"maps"
 	"net/http"
+	"slices"
+	"strings"
 	"time"
-
-	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/bson/primitive"
-	"google.golang.org/genai"
 )
 
 type ScoreRequest struct {
-	StoryID            string   `json:"story_id"`
-	Theory             string   `json:"theory"`
-	DiscoveredEvidence []string `json:"discovered_evidence,omitempty"`
+	SessionID string `json:"session_id"`
+	StoryID   string `json:"story_id,omitempty"` // Optional, must match the session's story
+	Theory    string `json:"theory"`
+
+	// Accusation, when set, names the culprits and cites evidence explicitly. If the
+	// story has a machine-readable solution it is checked without the LLM.
+	Accusation *Accusation `json:"accusation,omitempty"`
+
+	// Ensemble, when set, scores the theory with several independent judgments
+	Ensemble *EnsembleOptions `json:"ensemble,omitempty"`
 }
 
 type ScoreResponse struct {
-	Score  int    `json:"score"`
-	Reason string `json:"reason"`
+	Score            int            `json:"score"`
+	Reason           string         `json:"reason"`
+	CulpritCorrect   bool           `json:"culprit_correct"`
+	Breakdown        ScoreBreakdown `json:"breakdown"`
+	EvidenceUsedWell []string       `json:"evidence_used_well"`
+	EvidenceMissed   []string       `json:"evidence_missed"`
+	// AccusationChecked is true when the culprit and evidence were graded against the story's solution
+	AccusationChecked bool            `json:"accusation_checked"`
+	Ensemble          *EnsembleResult `json:"ensemble,omitempty"`
+	SubmissionID      string          `json:"submission_id,omitempty"`
 }
 
 // formatDiscoveredEvidence formats the discovered evidence for the scoring prompt
@@ -41,107 +60,152 @@ func formatDiscoveredEvidence(evidenceList []models.Evidence) string {
 }
 
 func ScoreTheoryHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
 	var req ScoreRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "Bad request", http.StatusBadRequest)
 		return
 	}
 
-	// Convert story ID string to ObjectID
-	storyObjID, err := primitive.ObjectIDFromHex(req.StoryID)
-	if err != nil {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid story ID"})
+	if req.SessionID == "" {
+		writeJSONError(w, http.StatusBadRequest, "session_id is required")
 		return
 	}
 
-	// Fetch story from MongoDB
+	if req.Ensemble != nil {
+		if err := req.Ensemble.validate(config.GetScoreEnsembleMaxJudges()); err != nil {
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	var story models.Story
-	collection := db.GetCollection("stories")
-	err = collection.FindOne(ctx, bson.M{"_id": storyObjID}).Decode(&story)
+	// Discovered evidence comes from the session, never from the client
+	session, ok := loadSession(ctx, w, r, req.SessionID)
+	if !ok {
+		return
+	}
+	if req.StoryID != "" && req.StoryID != session.StoryID.Hex() {
+		writeJSONError(w, http.StatusBadRequest, "Session belongs to a different story")
+		return
+	}
+
+	// Fetch story from MongoDB
+	story, err := fetchStoryFrom(ctx, session.StoryCollection, session.StoryID.Hex())
 	if err != nil {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusNotFound)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Story not found"})
+		writeJSONError(w, http.StatusNotFound, "Story not found")
 		return
 	}
 
-	// Fetch evidence details if provided
-	var evidenceDetails []models.Evidence
-	if len(req.DiscoveredEvidence) > 0 {
-		evidenceDetails, err = fetchEvidenceDetails(req.StoryID, req.DiscoveredEvidence)
-		if err != nil {
-			// Log the error but continue with scoring without evidence details
-			// This ensures backward compatibility
-			evidenceDetails = []models.Evidence{}
+	theory := req.Theory
+	if req.Accusation != nil {
+		if err := req.Accusation.validate(story); err != nil {
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+			return
 		}
+		theory += "\n\nFORMAL ACCUSATION:\n" + req.Accusation.describe(story)
 	}
 
-	// Create Gemini client
-	client, err := genai.NewClient(ctx, &genai.ClientConfig{
-		APIKey: config.GetGeminiAPIKey(),
-	})
-	if err != nil {
-		http.Error(w, "Failed to create AI client", http.StatusInternalServerError)
-		return
+	// With a solution on file the culprit and evidence criteria are graded
+	// deterministically, and the judge only grades the narrative
+	solution := story.Story.Solution
+	checkAccused := req.Accusation != nil && solution != nil
+	gradingNotes := ""
+	if checkAccused {
+		gradingNotes = fmt.Sprintf(`
+Note: the culprit and evidence criteria are checked automatically against the solution.
+Focus your judgment on motive, sequence and relationships. The actual motive is: %s
+`, strings.Join(solution.MotiveTags, ", "))
 	}
 
+	evidenceDetails := findStoryEvidence(story, session.DiscoveredEvidenceIDs)
+	allEvidence := findStoryEvidence(story, slices.Collect(maps.Keys(storyEvidenceIDs(story))))
+
 	// Construct prompt for scoring
 	prompt := fmt.Sprintf(`You are a mystery game judge. Compare the player's theory to the actual story and score their accuracy.
 
 ACTUAL STORY:
 %s
 
+ALL EVIDENCE IN THE CASE:
+%s
+
 EVIDENCE THE PLAYER HAS DISCOVERED:
 %s
 
 PLAYER'S THEORY:
 %s
 
-Score the player's theory based on:
-1. Correct identification of the culprit (30 points)
-2. Understanding of motive (20 points)
-3. Correct sequence of events (20 points)
-4. Effective use of discovered evidence (20 points)
+Score the player's theory on each criterion:
+1. culprit: Correct identification of the culprit (0-30 points)
+2. motive: Understanding of motive (0-20 points)
+3. sequence: Correct sequence of events (0-20 points)
+4. evidence: Effective use of discovered evidence (0-20 points)
    - Did they find the right evidence?
    - Did they correctly interpret the evidence?
    - Is their theory supported by the evidence they found?
-5. Understanding of relationships between characters (10 points)
+5. relationships: Understanding of relationships between characters (0-10 points)
 
 Additional considerations:
-- If they missed critical evidence, note what they should have found
+- If they missed critical evidence, list its ID in evidence_missed
 - If they have the right evidence but wrong conclusions, partial credit
-- Bonus points if they found particularly hidden or clever evidence
-
+- Only use evidence IDs exactly as written in the story
+%s
 Respond in JSON format:
 {
-  "score": <number between 0-100>,
+  "culprit_correct": <true if they named the actual culprit>,
+  "breakdown": {
+    "culprit": <0-30>,
+    "motive": <0-20>,
+    "sequence": <0-20>,
+    "evidence": <0-20>,
+    "relationships": <0-10>
+  },
+  "evidence_used_well": ["<IDs of discovered evidence they interpreted correctly>"],
+  "evidence_missed": ["<IDs of evidence they missed or misread>"],
   "reason": "<brief explanation including what evidence they used well or missed>"
 }
 
-Be fair but precise in scoring. If they got the main culprit wrong, they cannot score above 60.`,
+Be fair but precise in scoring.`,
 		story.Story.FullStory,
+		formatDiscoveredEvidence(allEvidence),
 		formatDiscoveredEvidence(evidenceDetails),
-		req.Theory)
+		theory,
+		gradingNotes)
+
+	if req.Ensemble != nil {
+		ensembleCtx, cancelEnsemble := context.WithTimeout(ctx, config.GetScoreEnsembleTimeout())
+		defer cancelEnsemble()
+
+		judgments, failed := runJudgeEnsemble(ensembleCtx, llm.Default(), prompt, req.Ensemble.Judges)
+		if len(judgments) == 0 {
+			writeJSONError(w, http.StatusInternalServerError, "Failed to generate score")
+			return
+		}
 
-	// Configure generation for JSON output
-	genConfig := &genai.GenerateContentConfig{
-		ResponseMIMEType: "application/json",
+		if checkAccused {
+			for i := range judgments {
+				judgments[i] = checkAccusation(judgments[i], solution, req.Accusation, session.DiscoveredEvidenceIDs)
+			}
+		}
+
+		scoreResp, result := aggregateJudgments(judgments, req.Ensemble.Aggregate, story, session.DiscoveredEvidenceIDs)
+		result.Failed = failed
+		scoreResp.Ensemble = &result
+		scoreResp.AccusationChecked = checkAccused
+		saveScoreSubmission(ctx, session, req.Theory, req.Accusation, &scoreResp)
+
+		log.Printf("[SCORE_ENSEMBLE] Session %s scored %d from %d judgments (%d failed, variance %.1f, low confidence %v)",
+			req.SessionID, scoreResp.Score, result.Judges, failed, result.Variance, result.LowConfidence)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(scoreResp)
+		return
 	}
 
-	// Get AI response
-	resp, err := client.Models.GenerateContent(ctx, config.GetGeminiModel(),
-		[]*genai.Content{genai.NewContentFromText(prompt, genai.RoleUser)},
-		genConfig)
+	// Get AI response as JSON
+	respText, err := llm.Default().Generate(ctx, prompt, llm.Options{JSON: true, Purpose: llm.PurposeScoring})
 	if err != nil {
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusInternalServerError)
@@ -152,8 +216,9 @@ Be fair but precise in scoring. If they got the main culprit wrong, they cannot
 	}
 
 	// Parse the JSON response
-	var scoreResp ScoreResponse
-	if err := json.Unmarshal([]byte(resp.Text()), &scoreResp); err != nil {
+	var judgment scoreJudgment
+	if err := json.Unmarshal([]byte(respText), &judgment); err != nil {
+		metrics.JSONParseFailed(llm.PurposeScoring)
 		// Fallback response if parsing fails
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusInternalServerError)
@@ -164,8 +229,56 @@ Be fair but precise in scoring. If they got the main culprit wrong, they cannot
 		return
 	}
 
+	if checkAccused {
+		judgment = checkAccusation(judgment, solution, req.Accusation, session.DiscoveredEvidenceIDs)
+	}
+
+	// The rubric, not the model, has the final say on the score
+	scoreResp := applyScoreRubric(judgment, story, session.DiscoveredEvidenceIDs)
+	scoreResp.AccusationChecked = checkAccused
+	saveScoreSubmission(ctx, session, req.Theory, req.Accusation, &scoreResp)
+
 	// Return the score
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(scoreResp)
 }
+
+// saveScoreSubmission stores a scored theory for the leaderboard and the player's
+// history. A failure is logged but does not withhold the score from the player.
+func saveScoreSubmission(ctx context.Context, session *dbModels.SessionDocument, theory string, accusation *Accusation, resp *ScoreResponse) {
+	now := time.Now()
+	submission := &dbModels.ScoreSubmissionDocument{
+		SessionID:      session.ID,
+		PlayerID:       session.PlayerID,
+		StoryID:        session.StoryID,
+		Theory:         theory,
+		Score:          resp.Score,
+		Reason:         resp.Reason,
+		CulpritCorrect: resp.CulpritCorrect,
+		Breakdown: dbModels.ScoreBreakdown{
+			Culprit:       resp.Breakdown.Culprit,
+			Motive:        resp.Breakdown.Motive,
+			Sequence:      resp.Breakdown.Sequence,
+			Evidence:      resp.Breakdown.Evidence,
+			Relationships: resp.Breakdown.Relationships,
+		},
+		DiscoveredEvidenceCount: len(session.DiscoveredEvidenceIDs),
+		MessageCount:            session.MessageCount,
+		TimeToSolve:             now.Sub(session.CreatedAt),
+		Ensemble:                resp.Ensemble != nil,
+		SubmittedAt:             now,
+	}
+	if accusation != nil {
+		submission.AccusedCulpritIDs = accusation.CulpritIDs
+		submission.CitedEvidenceIDs = accusation.EvidenceIDs
+	}
+
+	if err := db.SaveScoreSubmission(ctx, submission); err != nil {
+		log.Printf("[SCORE_ERROR] Failed to save submission for session %s: %v", session.ID.Hex(), err)
+	} else {
+		resp.SubmissionID = submission.ID.Hex()
+	}
+
+	events.Publish(session.ID.Hex(), events.TypeScoreResult, resp)
+}
@@ -0,0 +1,260 @@
+package handlers
+
+import (
+	"agent/llm"
+	"agent/metrics"
+	"agent/models"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"slices"
+	"sort"
+	"sync"
+)
+
+// Aggregation methods for ensemble scoring
+const (
+	AggregateMedian      = "median"
+	AggregateTrimmedMean = "trimmed_mean"
+
+	defaultEnsembleJudges = 3
+
+	// lowConfidenceStdDev is the spread of final scores above which a result is flagged
+	lowConfidenceStdDev = 10.0
+)
+
+// EnsembleOptions asks /score to run several independent judgments and aggregate them
+type EnsembleOptions struct {
+	Judges    int    `json:"judges,omitempty"`    // Defaults to 3
+	Aggregate string `json:"aggregate,omitempty"` // "median" (default) or "trimmed_mean"
+}
+
+// EnsembleResult describes how an ensemble score was reached
+type EnsembleResult struct {
+	Judges            int               `json:"judges"`
+	Failed            int               `json:"failed"`
+	Aggregate         string            `json:"aggregate"`
+	Scores            []int             `json:"scores"`
+	Variance          float64           `json:"variance"`
+	BreakdownVariance BreakdownVariance `json:"breakdown_variance"`
+	CulpritAgreement  float64           `json:"culprit_agreement"`
+	LowConfidence     bool              `json:"low_confidence"`
+}
+
+// BreakdownVariance holds the variance of each criterion across judgments
+type BreakdownVariance struct {
+	Culprit       float64 `json:"culprit"`
+	Motive        float64 `json:"motive"`
+	Sequence      float64 `json:"sequence"`
+	Evidence      float64 `json:"evidence"`
+	Relationships float64 `json:"relationships"`
+}
+
+// validate fills in defaults and rejects options outside the allowed range
+func (o *EnsembleOptions) validate(maxJudges int) error {
+	if o.Judges == 0 {
+		o.Judges = defaultEnsembleJudges
+	}
+	if o.Judges < 2 || o.Judges > maxJudges {
+		return fmt.Errorf("ensemble judges must be between 2 and %d", maxJudges)
+	}
+
+	switch o.Aggregate {
+	case "":
+		o.Aggregate = AggregateMedian
+	case AggregateMedian, AggregateTrimmedMean:
+	default:
+		return fmt.Errorf("ensemble aggregate must be %s or %s", AggregateMedian, AggregateTrimmedMean)
+	}
+	return nil
+}
+
+// judgeTheory runs a single judgment of the scoring prompt
+func judgeTheory(ctx context.Context, generator llm.Generator, prompt string) (scoreJudgment, error) {
+	respText, err := generator.Generate(ctx, prompt, llm.Options{JSON: true, Purpose: llm.PurposeScoring})
+	if err != nil {
+		return scoreJudgment{}, err
+	}
+
+	var judgment scoreJudgment
+	if err := json.Unmarshal([]byte(respText), &judgment); err != nil {
+		metrics.JSONParseFailed(llm.PurposeScoring)
+		return scoreJudgment{}, fmt.Errorf("failed to parse judgment: %w", err)
+	}
+	return judgment, nil
+}
+
+// runJudgeEnsemble runs n judgments concurrently and returns those that finished
+// before ctx expired, in completion order, along with the number that failed
+func runJudgeEnsemble(ctx context.Context, generator llm.Generator, prompt string, n int) ([]scoreJudgment, int) {
+	var (
+		mu        sync.Mutex
+		wg        sync.WaitGroup
+		judgments []scoreJudgment
+	)
+
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			judgment, err := judgeTheory(ctx, generator, prompt)
+			if err != nil {
+				log.Printf("[SCORE_ENSEMBLE_WARNING] Judgment %d/%d failed: %v", i+1, n, err)
+				return
+			}
+			mu.Lock()
+			judgments = append(judgments, judgment)
+			mu.Unlock()
+		}(i)
+	}
+	wg.Wait()
+
+	return judgments, n - len(judgments)
+}
+
+// aggregateJudgments combines several judgments into one scored response. Each
+// judgment is run through the rubric first so out-of-range points can't skew the
+// aggregate; the culprit verdict and evidence lists follow the majority.
+func aggregateJudgments(judgments []scoreJudgment, method string, story *models.Story, discoveredEvidenceIDs []string) (ScoreResponse, EnsembleResult) {
+	n := len(judgments)
+	scored := make([]ScoreResponse, n)
+	var (
+		culprit, motive, sequence, evidence, relationships []float64
+		scores                                             []float64
+		culpritVotes                                       int
+	)
+	for i, j := range judgments {
+		scored[i] = applyScoreRubric(j, story, discoveredEvidenceIDs)
+		b := scored[i].Breakdown
+		culprit = append(culprit, float64(b.Culprit))
+		motive = append(motive, float64(b.Motive))
+		sequence = append(sequence, float64(b.Sequence))
+		evidence = append(evidence, float64(b.Evidence))
+		relationships = append(relationships, float64(b.Relationships))
+		scores = append(scores, float64(scored[i].Score))
+		if j.CulpritCorrect {
+			culpritVotes++
+		}
+	}
+
+	aggregate := medianOf
+	if method == AggregateTrimmedMean {
+		aggregate = trimmedMeanOf
+	}
+
+	combined := scoreJudgment{
+		CulpritCorrect: culpritVotes*2 > n,
+		Breakdown: ScoreBreakdown{
+			Culprit:       int(math.Round(aggregate(culprit))),
+			Motive:        int(math.Round(aggregate(motive))),
+			Sequence:      int(math.Round(aggregate(sequence))),
+			Evidence:      int(math.Round(aggregate(evidence))),
+			Relationships: int(math.Round(aggregate(relationships))),
+		},
+		EvidenceUsedWell: majorityIDs(scored, func(s ScoreResponse) []string { return s.EvidenceUsedWell }),
+		EvidenceMissed:   majorityIDs(scored, func(s ScoreResponse) []string { return s.EvidenceMissed }),
+	}
+	resp := applyScoreRubric(combined, story, discoveredEvidenceIDs)
+
+	// Explain the result with the reason of the judgment closest to it
+	closest := 0
+	for i := range scored {
+		if abs(scored[i].Score-resp.Score) < abs(scored[closest].Score-resp.Score) {
+			closest = i
+		}
+	}
+	resp.Reason = scored[closest].Reason
+
+	result := EnsembleResult{
+		Judges:    n,
+		Aggregate: method,
+		Variance:  varianceOf(scores),
+		BreakdownVariance: BreakdownVariance{
+			Culprit:       varianceOf(culprit),
+			Motive:        varianceOf(motive),
+			Sequence:      varianceOf(sequence),
+			Evidence:      varianceOf(evidence),
+			Relationships: varianceOf(relationships),
+		},
+		CulpritAgreement: float64(max(culpritVotes, n-culpritVotes)) / float64(n),
+	}
+	for _, s := range scored {
+		result.Scores = append(result.Scores, s.Score)
+	}
+	result.LowConfidence = math.Sqrt(result.Variance) > lowConfidenceStdDev || result.CulpritAgreement < 1
+
+	return resp, result
+}
+
+// majorityIDs returns the IDs listed by more than half of the responses,
+// in the order they first appear
+func majorityIDs(responses []ScoreResponse, ids func(ScoreResponse) []string) []string {
+	counts := map[string]int{}
+	var order []string
+	for _, r := range responses {
+		for _, id := range ids(r) {
+			if counts[id] == 0 {
+				order = append(order, id)
+			}
+			counts[id]++
+		}
+	}
+
+	var result []string
+	for _, id := range order {
+		if counts[id]*2 > len(responses) {
+			result = append(result, id)
+		}
+	}
+	return result
+}
+
+// medianOf returns the median of values, averaging the middle two for even counts
+func medianOf(values []float64) float64 {
+	sorted := slices.Clone(values)
+	sort.Float64s(sorted)
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[mid-1] + sorted[mid]) / 2
+	}
+	return sorted[mid]
+}
+
+// trimmedMeanOf drops the top and bottom 20% of values (at least one each once
+// there are three or more) and averages the rest
+func trimmedMeanOf(values []float64) float64 {
+	sorted := slices.Clone(values)
+	sort.Float64s(sorted)
+	trim := 0
+	if len(sorted) >= 3 {
+		trim = max(1, len(sorted)/5)
+	}
+	return meanOf(sorted[trim : len(sorted)-trim])
+}
+
+func meanOf(values []float64) float64 {
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
+
+// varianceOf returns the population variance of values
+func varianceOf(values []float64) float64 {
+	mean := meanOf(values)
+	var sum float64
+	for _, v := range values {
+		sum += (v - mean) * (v - mean)
+	}
+	return sum / float64(len(values))
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
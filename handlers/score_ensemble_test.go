@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"agent/llm"
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMedianAndTrimmedMean(t *testing.T) {
+	if got := medianOf([]float64{30, 0, 25}); got != 25 {
+		t.Errorf("median of odd count = %v, want 25", got)
+	}
+	if got := medianOf([]float64{10, 20, 0, 30}); got != 15 {
+		t.Errorf("median of even count = %v, want 15", got)
+	}
+	// The outliers at both ends are dropped
+	if got := trimmedMeanOf([]float64{0, 18, 20, 22, 100}); got != 20 {
+		t.Errorf("trimmed mean = %v, want 20", got)
+	}
+	if got := trimmedMeanOf([]float64{10, 20}); got != 15 {
+		t.Errorf("trimmed mean of two = %v, want 15", got)
+	}
+}
+
+func TestEnsembleOptionsValidate(t *testing.T) {
+	opts := EnsembleOptions{}
+	if err := opts.validate(5); err != nil || opts.Judges != defaultEnsembleJudges || opts.Aggregate != AggregateMedian {
+		t.Errorf("unexpected defaults %+v (err %v)", opts, err)
+	}
+	for _, bad := range []EnsembleOptions{{Judges: 1}, {Judges: 6}, {Judges: 3, Aggregate: "mode"}} {
+		if err := bad.validate(5); err == nil {
+			t.Errorf("expected %+v to be rejected", bad)
+		}
+	}
+}
+
+func TestAggregateJudgmentsFollowsMajority(t *testing.T) {
+	judgments := []scoreJudgment{
+		{CulpritCorrect: true, Breakdown: ScoreBreakdown{30, 15, 15, 15, 10}, EvidenceUsedWell: []string{"evid_1"}, Reason: "good"},
+		{CulpritCorrect: true, Breakdown: ScoreBreakdown{30, 20, 15, 10, 5}, EvidenceUsedWell: []string{"evid_1", "evid_3"}, Reason: "best"},
+		{CulpritCorrect: false, Breakdown: ScoreBreakdown{30, 0, 5, 5, 0}, Reason: "outlier"},
+	}
+
+	resp, result := aggregateJudgments(judgments, AggregateMedian, newScoringStory(), []string{"evid_1", "evid_3"})
+
+	if !resp.CulpritCorrect {
+		t.Error("expected the majority culprit verdict")
+	}
+	want := ScoreBreakdown{Culprit: 30, Motive: 15, Sequence: 15, Evidence: 10, Relationships: 5}
+	if resp.Breakdown != want || resp.Score != want.Total() {
+		t.Errorf("breakdown = %+v (score %d), want %+v", resp.Breakdown, resp.Score, want)
+	}
+	if !reflect.DeepEqual(resp.EvidenceUsedWell, []string{"evid_1"}) {
+		t.Errorf("evidence_used_well = %v, want only the majority pick", resp.EvidenceUsedWell)
+	}
+	if resp.Reason == "outlier" {
+		t.Error("expected the reason of a judgment close to the aggregate")
+	}
+
+	if !reflect.DeepEqual(result.Scores, []int{85, 80, 10}) {
+		t.Errorf("scores = %v", result.Scores)
+	}
+	if result.Variance < 100 || !result.LowConfidence {
+		t.Errorf("expected a split ensemble to be low confidence, got %+v", result)
+	}
+	// The rubric zeroes the wrong culprit's points before the variance is taken
+	if result.BreakdownVariance.Culprit != 200 {
+		t.Errorf("culprit variance = %v, want 200", result.BreakdownVariance.Culprit)
+	}
+}
+
+// stallingGenerator answers the first calls and blocks the rest until the deadline
+type stallingGenerator struct {
+	llm.Fake
+	answer chan struct{}
+}
+
+func (g *stallingGenerator) Generate(ctx context.Context, prompt string, opts llm.Options) (string, error) {
+	select {
+	case <-g.answer:
+		return `{"culprit_correct": true, "breakdown": {"culprit": 30}}`, nil
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+func TestRunJudgeEnsembleDropsLateJudgments(t *testing.T) {
+	gen := &stallingGenerator{answer: make(chan struct{}, 2)}
+	gen.answer <- struct{}{}
+	gen.answer <- struct{}{}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	judgments, failed := runJudgeEnsemble(ctx, gen, "judge this", 4)
+	if len(judgments) != 2 || failed != 2 {
+		t.Fatalf("expected 2 judgments and 2 failures, got %d and %d", len(judgments), failed)
+	}
+	if !strings.Contains(ctx.Err().Error(), "deadline") {
+		t.Errorf("expected the ensemble to wait for the deadline, got %v", ctx.Err())
+	}
+}
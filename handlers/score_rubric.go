@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"agent/models"
+	"slices"
+)
+
+// Points available for each scoring criterion
+const (
+	maxCulpritPoints       = 30
+	maxMotivePoints        = 20
+	maxSequencePoints      = 20
+	maxEvidencePoints      = 20
+	maxRelationshipsPoints = 10
+
+	// wrongCulpritScoreCap is the highest score a theory naming the wrong culprit can get
+	wrongCulpritScoreCap = 60
+)
+
+// ScoreBreakdown holds the points awarded for each criterion
+type ScoreBreakdown struct {
+	Culprit       int `json:"culprit"`
+	Motive        int `json:"motive"`
+	Sequence      int `json:"sequence"`
+	Evidence      int `json:"evidence"`
+	Relationships int `json:"relationships"`
+}
+
+// Total returns the sum of all criteria
+func (b ScoreBreakdown) Total() int {
+	return b.Culprit + b.Motive + b.Sequence + b.Evidence + b.Relationships
+}
+
+// scoreJudgment is the JSON the judge model is asked to return
+type scoreJudgment struct {
+	CulpritCorrect   bool           `json:"culprit_correct"`
+	Breakdown        ScoreBreakdown `json:"breakdown"`
+	EvidenceUsedWell []string       `json:"evidence_used_well"`
+	EvidenceMissed   []string       `json:"evidence_missed"`
+	Reason           string         `json:"reason"`
+}
+
+// applyScoreRubric turns the judge's raw judgment into a score the server vouches for:
+// criteria are clamped to their maximum, evidence IDs are checked against the story and
+// the session, and a wrong culprit earns no culprit points and caps the total
+func applyScoreRubric(j scoreJudgment, story *models.Story, discoveredEvidenceIDs []string) ScoreResponse {
+	b := ScoreBreakdown{
+		Culprit:       clampPoints(j.Breakdown.Culprit, maxCulpritPoints),
+		Motive:        clampPoints(j.Breakdown.Motive, maxMotivePoints),
+		Sequence:      clampPoints(j.Breakdown.Sequence, maxSequencePoints),
+		Evidence:      clampPoints(j.Breakdown.Evidence, maxEvidencePoints),
+		Relationships: clampPoints(j.Breakdown.Relationships, maxRelationshipsPoints),
+	}
+	if !j.CulpritCorrect {
+		b.Culprit = 0
+	}
+
+	score := b.Total()
+	if !j.CulpritCorrect {
+		score = min(score, wrongCulpritScoreCap)
+	}
+
+	storyEvidence := storyEvidenceIDs(story)
+
+	// A player can only have used evidence they actually discovered
+	var usedWell []string
+	for _, id := range j.EvidenceUsedWell {
+		if storyEvidence[id] && slices.Contains(discoveredEvidenceIDs, id) && !slices.Contains(usedWell, id) {
+			usedWell = append(usedWell, id)
+		}
+	}
+
+	var missed []string
+	for _, id := range j.EvidenceMissed {
+		if storyEvidence[id] && !slices.Contains(usedWell, id) && !slices.Contains(missed, id) {
+			missed = append(missed, id)
+		}
+	}
+
+	return ScoreResponse{
+		Score:            score,
+		Reason:           j.Reason,
+		CulpritCorrect:   j.CulpritCorrect,
+		Breakdown:        b,
+		EvidenceUsedWell: usedWell,
+		EvidenceMissed:   missed,
+	}
+}
+
+// clampPoints keeps a criterion's points between 0 and its maximum
+func clampPoints(points, maxPoints int) int {
+	return max(0, min(points, maxPoints))
+}
@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"agent/models"
+	"reflect"
+	"testing"
+)
+
+func newScoringStory() *models.Story {
+	return &models.Story{Story: models.StoryContent{
+		Characters: []models.Character{{
+			ID:            "char_1",
+			HoldsEvidence: []models.Evidence{{ID: "evid_1"}, {ID: "evid_2"}},
+		}},
+		Locations: []models.Location{{
+			ID:         "loc_1",
+			Containers: []models.Container{{ID: "safe_1", ContainsEvidence: []models.Evidence{{ID: "evid_3"}}}},
+		}},
+	}}
+}
+
+func TestApplyScoreRubricClampsCriteria(t *testing.T) {
+	resp := applyScoreRubric(scoreJudgment{
+		CulpritCorrect: true,
+		Breakdown:      ScoreBreakdown{Culprit: 45, Motive: 20, Sequence: -5, Evidence: 25, Relationships: 10},
+	}, newScoringStory(), nil)
+
+	want := ScoreBreakdown{Culprit: 30, Motive: 20, Sequence: 0, Evidence: 20, Relationships: 10}
+	if resp.Breakdown != want {
+		t.Errorf("breakdown = %+v, want %+v", resp.Breakdown, want)
+	}
+	if resp.Score != 80 {
+		t.Errorf("score = %d, want 80", resp.Score)
+	}
+}
+
+func TestApplyScoreRubricCapsWrongCulprit(t *testing.T) {
+	// The judge ignored the rule and gave culprit points to a wrong accusation
+	resp := applyScoreRubric(scoreJudgment{
+		CulpritCorrect: false,
+		Breakdown:      ScoreBreakdown{Culprit: 30, Motive: 20, Sequence: 20, Evidence: 20, Relationships: 10},
+	}, newScoringStory(), nil)
+
+	if resp.Breakdown.Culprit != 0 {
+		t.Errorf("expected no culprit points, got %d", resp.Breakdown.Culprit)
+	}
+	if resp.Score != wrongCulpritScoreCap {
+		t.Errorf("score = %d, want %d", resp.Score, wrongCulpritScoreCap)
+	}
+}
+
+func TestApplyScoreRubricValidatesEvidenceIDs(t *testing.T) {
+	resp := applyScoreRubric(scoreJudgment{
+		CulpritCorrect:   true,
+		EvidenceUsedWell: []string{"evid_1", "evid_1", "evid_2", "evid_404"},
+		EvidenceMissed:   []string{"evid_3", "evid_1", "made_up"},
+	}, newScoringStory(), []string{"evid_1", "evid_3"})
+
+	// evid_2 was never discovered in the session, evid_404 is not in the story
+	if !reflect.DeepEqual(resp.EvidenceUsedWell, []string{"evid_1"}) {
+		t.Errorf("evidence_used_well = %v", resp.EvidenceUsedWell)
+	}
+	if !reflect.DeepEqual(resp.EvidenceMissed, []string{"evid_3"}) {
+		t.Errorf("evidence_missed = %v", resp.EvidenceMissed)
+	}
+}
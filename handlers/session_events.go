@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"agent/events"
+	"agent/middleware"
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	eventWriteWait  = 10 * time.Second       // Time allowed to write one event
+	eventPongWait   = 60 * time.Second       // Time allowed between pongs from the client
+	eventPingPeriod = eventPongWait * 9 / 10 // Must be shorter than eventPongWait
+)
+
+// TypeSessionSnapshot is sent once when a client connects, carrying the session
+// as GET /sessions/{id} would return it, so the client starts from current state
+const TypeSessionSnapshot = "session_snapshot"
+
+var sessionEventsUpgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	// Browsers don't apply CORS to WebSockets, so check the origin here.
+	// Non-browser clients send no Origin and are let through.
+	CheckOrigin: func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		return origin == "" || middleware.OriginAllowed(origin)
+	},
+}
+
+// SessionEventsHandler upgrades GET /sessions/{id}/events to a WebSocket that
+// pushes the session's events as JSON messages. The socket is write-only;
+// anything the client sends is ignored.
+func SessionEventsHandler(w http.ResponseWriter, r *http.Request) {
+	sessionID := r.PathValue("id")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	session, ok := loadSession(ctx, w, r, sessionID)
+	if !ok {
+		return
+	}
+
+	// Subscribe before the snapshot so nothing that happens in between is missed
+	ch, unsubscribe := events.Default.Subscribe(sessionID)
+	defer unsubscribe()
+
+	conn, err := sessionEventsUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade has already written the error response
+		log.Printf("[EVENTS_ERROR] Failed to upgrade connection for session %s: %v", sessionID, err)
+		return
+	}
+	defer conn.Close()
+
+	log.Printf("[EVENTS] Client connected to session %s", sessionID)
+
+	closed := make(chan struct{})
+	go readSessionEvents(conn, closed)
+
+	snapshot := events.Event{
+		Type:      TypeSessionSnapshot,
+		SessionID: sessionID,
+		Data:      newSessionResponse(session),
+		Time:      time.Now(),
+	}
+	if err := writeSessionEvent(conn, snapshot); err != nil {
+		return
+	}
+
+	ticker := time.NewTicker(eventPingPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case event, ok := <-ch:
+			if !ok {
+				return
+			}
+			if err := writeSessionEvent(conn, event); err != nil {
+				log.Printf("[EVENTS] Client of session %s went away: %v", sessionID, err)
+				return
+			}
+		case <-ticker.C:
+			conn.SetWriteDeadline(time.Now().Add(eventWriteWait))
+			if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		case <-closed:
+			log.Printf("[EVENTS] Client disconnected from session %s", sessionID)
+			return
+		}
+	}
+}
+
+func writeSessionEvent(conn *websocket.Conn, event events.Event) error {
+	conn.SetWriteDeadline(time.Now().Add(eventWriteWait))
+	return conn.WriteJSON(event)
+}
+
+// readSessionEvents drains client messages so pongs and close frames are
+// processed, and closes done once the connection is gone
+func readSessionEvents(conn *websocket.Conn, done chan<- struct{}) {
+	defer close(done)
+
+	conn.SetReadLimit(512)
+	conn.SetReadDeadline(time.Now().Add(eventPongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(eventPongWait))
+	})
+	for {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			return
+		}
+	}
+}
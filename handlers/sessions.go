@@ -0,0 +1,239 @@
+package handlers
+
+import (
+	"agent/auth"
+	"agent/db"
+	dbModels "agent/db/models"
+	"agent/models"
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"slices"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type SessionRequest struct {
+	PlayerID   string `json:"player_id"`
+	StoryID    string `json:"story_id"`
+	Collection string `json:"collection,omitempty"` // Story catalog alias, as for /v2/feed
+}
+
+type SessionResponse struct {
+	ID                    string            `json:"id"`
+	PlayerID              string            `json:"player_id"`
+	StoryID               string            `json:"story_id"`
+	DiscoveredEvidenceIDs []string          `json:"discovered_evidence_ids"`
+	UnlockedLocationIDs   []string          `json:"unlocked_location_ids"`
+	OpenedContainerIDs    []string          `json:"opened_container_ids"`
+	Agents                map[string]string `json:"agents"`
+	MessageCount          int               `json:"message_count"`
+	CreatedAt             time.Time         `json:"created_at"`
+	UpdatedAt             time.Time         `json:"updated_at"`
+}
+
+// newSessionResponse converts a session document into its API shape
+func newSessionResponse(s *dbModels.SessionDocument) SessionResponse {
+	return SessionResponse{
+		ID:                    s.ID.Hex(),
+		PlayerID:              s.PlayerID,
+		StoryID:               s.StoryID.Hex(),
+		DiscoveredEvidenceIDs: s.DiscoveredEvidenceIDs,
+		UnlockedLocationIDs:   s.UnlockedLocationIDs,
+		OpenedContainerIDs:    s.OpenedContainerIDs,
+		Agents:                s.AgentIDs,
+		MessageCount:          s.MessageCount,
+		CreatedAt:             s.CreatedAt,
+		UpdatedAt:             s.UpdatedAt,
+	}
+}
+
+// SessionCreateHandler starts a new investigation of a story for a player
+func SessionCreateHandler(w http.ResponseWriter, r *http.Request) {
+	var req SessionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+
+	var ok bool
+	if req.PlayerID, ok = resolvePlayerID(w, r, req.PlayerID); !ok {
+		return
+	}
+	if req.PlayerID == "" {
+		writeJSONError(w, http.StatusBadRequest, "player_id is required")
+		return
+	}
+
+	storyObjID, err := primitive.ObjectIDFromHex(req.StoryID)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid story ID")
+		return
+	}
+
+	collectionName, ok := storyCollectionForAlias(req.Collection)
+	if !ok {
+		writeJSONError(w, http.StatusBadRequest, "Unknown story collection")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	story, err := fetchStoryFrom(ctx, collectionName, req.StoryID)
+	if err != nil {
+		writeJSONError(w, http.StatusNotFound, "Story not found")
+		return
+	}
+
+	session := &dbModels.SessionDocument{
+		PlayerID:            req.PlayerID,
+		StoryID:             storyObjID,
+		StoryCollection:     collectionName,
+		UnlockedLocationIDs: append([]string{}, story.Story.StartingLocationIDs...),
+	}
+	if _, err := db.CreateSession(ctx, session); err != nil {
+		log.Printf("[SESSION_ERROR] Failed to create session for player %s: %v", req.PlayerID, err)
+		writeJSONError(w, http.StatusInternalServerError, "Failed to create session")
+		return
+	}
+
+	log.Printf("[SESSION_CREATE] Created session %s for player %s on story %s", session.ID.Hex(), req.PlayerID, req.StoryID)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(newSessionResponse(session))
+}
+
+// SessionResumeHandler returns the player's most recent session for a story
+func SessionResumeHandler(w http.ResponseWriter, r *http.Request) {
+	var req SessionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+
+	var ok bool
+	if req.PlayerID, ok = resolvePlayerID(w, r, req.PlayerID); !ok {
+		return
+	}
+	if req.PlayerID == "" {
+		writeJSONError(w, http.StatusBadRequest, "player_id is required")
+		return
+	}
+
+	storyObjID, err := primitive.ObjectIDFromHex(req.StoryID)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid story ID")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	session, err := db.FindLatestSession(ctx, req.PlayerID, storyObjID)
+	if errors.Is(err, db.ErrSessionNotFound) {
+		writeJSONError(w, http.StatusNotFound, "No session to resume")
+		return
+	}
+	if err != nil {
+		log.Printf("[SESSION_ERROR] Failed to resume session for player %s: %v", req.PlayerID, err)
+		writeJSONError(w, http.StatusInternalServerError, "Failed to load session")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(newSessionResponse(session))
+}
+
+// SessionDetailRESTHandler handles RESTful paths like /sessions/{id}
+func SessionDetailRESTHandler(w http.ResponseWriter, r *http.Request) {
+	sessionID := r.PathValue("id")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	session, ok := loadSession(ctx, w, r, sessionID)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(newSessionResponse(session))
+}
+
+// SessionLocationHandler handles /sessions/{id}/locations/{loc}, describing a
+// location the session has unlocked the way a player sees it on arrival
+func SessionLocationHandler(w http.ResponseWriter, r *http.Request) {
+	sessionID := r.PathValue("id")
+	locationID := r.PathValue("loc")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	session, ok := loadSession(ctx, w, r, sessionID)
+	if !ok {
+		return
+	}
+	if !slices.Contains(session.UnlockedLocationIDs, locationID) {
+		writeJSONError(w, http.StatusForbidden, "Location not unlocked in this session")
+		return
+	}
+
+	story, err := fetchStoryFrom(ctx, session.StoryCollection, session.StoryID.Hex())
+	if err != nil {
+		writeJSONError(w, http.StatusNotFound, "Story not found")
+		return
+	}
+	location := findLocation(story, locationID)
+	if location == nil {
+		writeJSONError(w, http.StatusNotFound, "Location not found")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.NewPublicLocation(*location))
+}
+
+// loadSession fetches a session owned by the requesting player and writes the
+// matching error response on failure
+func loadSession(ctx context.Context, w http.ResponseWriter, r *http.Request, sessionID string) (*dbModels.SessionDocument, bool) {
+	if _, err := primitive.ObjectIDFromHex(sessionID); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid session ID")
+		return nil, false
+	}
+
+	session, err := db.GetSession(ctx, sessionID)
+	if errors.Is(err, db.ErrSessionNotFound) {
+		writeJSONError(w, http.StatusNotFound, "Session not found")
+		return nil, false
+	}
+	if err != nil {
+		log.Printf("[SESSION_ERROR] Failed to load session %s: %v", sessionID, err)
+		writeJSONError(w, http.StatusInternalServerError, "Failed to load session")
+		return nil, false
+	}
+	if !auth.Owns(r.Context(), session.PlayerID) {
+		writeJSONError(w, http.StatusForbidden, "Session belongs to another player")
+		return nil, false
+	}
+
+	return session, true
+}
+
+// resolvePlayerID fills in the request's player ID from the token. A player ID
+// that doesn't match the token is rejected with 403.
+func resolvePlayerID(w http.ResponseWriter, r *http.Request, requested string) (string, bool) {
+	player := auth.PlayerID(r.Context())
+	if player == "" {
+		return requested, true
+	}
+	if requested != "" && requested != player {
+		writeJSONError(w, http.StatusForbidden, "player_id does not match the token")
+		return "", false
+	}
+	return player, true
+}
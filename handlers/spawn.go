@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"agent/agent"
+	"agent/auth"
+	"agent/db"
+	dbModels "agent/db/models"
+	"agent/events"
+	"agent/prompts"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type SpawnRequest struct {
+	StoryID     string `json:"story_id"`
+	CharacterID string `json:"character_id"`
+	SessionID   string `json:"session_id,omitempty"`
+	Collection  string `json:"collection,omitempty"` // Story catalog alias without a session; sessions use their own
+}
+
+type SpawnResponse struct {
+	AgentID string `json:"agent_id"`
+}
+
+// CharacterSpawnedEvent is published to the session when a character's agent is created
+type CharacterSpawnedEvent struct {
+	CharacterID   string `json:"character_id"`
+	CharacterName string `json:"character_name"`
+	AgentID       string `json:"agent_id"`
+}
+
+func SpawnAgentHandler(w http.ResponseWriter, r *http.Request) {
+	var req SpawnRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+
+	if req.CharacterID == "" {
+		writeJSONError(w, http.StatusBadRequest, "character_id is required")
+		return
+	}
+
+	storyObjID, err := primitive.ObjectIDFromHex(req.StoryID)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid story ID")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	collectionName, ok := storyCollectionForAlias(req.Collection)
+	if !ok {
+		writeJSONError(w, http.StatusBadRequest, "Unknown story collection")
+		return
+	}
+
+	// The agent belongs to the session's player, or to the caller without a session
+	playerID := auth.PlayerID(r.Context())
+	if req.SessionID != "" {
+		session, ok := loadSession(ctx, w, r, req.SessionID)
+		if !ok {
+			return
+		}
+		playerID = session.PlayerID
+		collectionName = session.StoryCollection
+		if session.StoryID != storyObjID {
+			writeJSONError(w, http.StatusBadRequest, "Session belongs to a different story")
+			return
+		}
+		// Each session talks to a single agent per character
+		if agentID, ok := session.AgentIDs[req.CharacterID]; ok {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(SpawnResponse{AgentID: agentID})
+			return
+		}
+	}
+
+	story, err := fetchStoryFrom(ctx, collectionName, req.StoryID)
+	if err != nil {
+		writeJSONError(w, http.StatusNotFound, "Story not found")
+		return
+	}
+
+	character := findCharacter(story, req.CharacterID)
+	if character == nil {
+		writeJSONError(w, http.StatusNotFound, "Character not found")
+		return
+	}
+
+	systemPrompt, evidenceIDs := prompts.ConstructCharacterSystemPrompt(character, story)
+
+	agentDoc := &dbModels.AgentDocument{
+		StoryID:             storyObjID,
+		StoryCollection:     collectionName,
+		SessionID:           req.SessionID,
+		PlayerID:            playerID,
+		CharacterID:         character.ID,
+		CharacterName:       character.Name,
+		Personality:         character.PersonalityProfile,
+		HoldsEvidenceIDs:    evidenceIDs,
+		KnowsLocationIDs:    character.KnowsLocationIDs,
+		RevealedEvidenceIDs: map[string]bool{},
+		RevealedLocationIDs: map[string]bool{},
+		Reputation:          character.BaseReputation,
+		Intimidation:        character.BaseIntimidation,
+	}
+
+	agentObjID, err := db.CreateAgent(ctx, agentDoc)
+	if errors.Is(err, db.ErrAgentExists) {
+		// A concurrent spawn for the same session and character won the race
+		agentID, err := db.FindSessionAgent(ctx, req.SessionID, character.ID)
+		if err != nil {
+			log.Printf("[SPAWN_ERROR] Failed to find existing agent for character %s: %v", character.ID, err)
+			writeJSONError(w, http.StatusInternalServerError, "Failed to create agent")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(SpawnResponse{AgentID: agentID})
+		return
+	}
+	if err != nil {
+		log.Printf("[SPAWN_ERROR] Failed to create agent for character %s: %v", character.ID, err)
+		writeJSONError(w, http.StatusInternalServerError, "Failed to create agent")
+		return
+	}
+	agentID := agentObjID.Hex()
+
+	agent.SpawnAgentWithCharacterAndID(agentID, systemPrompt, story.Story.FullStory, req.StoryID, collectionName, req.SessionID,
+		playerID, character.ID, character.Name, character.PersonalityProfile, evidenceIDs, character.KnowsLocationIDs,
+		agent.Standing{Reputation: character.BaseReputation, Intimidation: character.BaseIntimidation})
+
+	// Persist the system prompt as message 0 so LoadAgentFromDatabase can rebuild the agent
+	fullSystemPrompt := fmt.Sprintf("%s\n\n[STORY CONTEXT FOR REFERENCE]:\n%s", systemPrompt, story.Story.FullStory)
+	if err := db.SaveConversationMessageWithVersions(ctx, agentID, fullSystemPrompt,
+		extractClientContent(fullSystemPrompt, "model"), "model", 0, nil, nil); err != nil {
+		log.Printf("[SPAWN_WARNING] Failed to persist system prompt for agent %s: %v", agentID, err)
+	}
+
+	if req.SessionID != "" {
+		if err := db.SetSessionAgent(ctx, req.SessionID, character.ID, agentID); err != nil {
+			log.Printf("[SPAWN_WARNING] Failed to record agent %s on session %s: %v", agentID, req.SessionID, err)
+		}
+		events.Publish(req.SessionID, events.TypeCharacterSpawned, CharacterSpawnedEvent{
+			CharacterID:   character.ID,
+			CharacterName: character.Name,
+			AgentID:       agentID,
+		})
+	}
+
+	log.Printf("[SPAWN] Spawned agent %s as %s for story %s", agentID, character.Name, req.StoryID)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(SpawnResponse{AgentID: agentID})
+}
+
+// writeJSONError writes a {"error": message} body with the given status code
+func writeJSONError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
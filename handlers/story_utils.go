@@ -1,31 +1,84 @@
 package handlers
 
 import (
+	"agent/config"
 	"agent/db"
 	"agent/models"
 	"context"
-	"time"
+	"net/http"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
-// fetchEvidenceDetails retrieves the full evidence documents for the requested IDs
-func fetchEvidenceDetails(storyID string, evidenceIDs []string) ([]models.Evidence, error) {
+// defaultStoryCollection holds the stories served when no catalog is chosen
+const defaultStoryCollection = "stories"
+
+// fetchStory loads and decodes a story document from the default collection
+func fetchStory(ctx context.Context, storyID string) (*models.Story, error) {
+	return fetchStoryFrom(ctx, defaultStoryCollection, storyID)
+}
+
+// fetchStoryFrom loads and decodes a story document from the given collection.
+// An empty name selects the default collection, as stored on sessions and
+// agents created before they recorded their story's collection.
+func fetchStoryFrom(ctx context.Context, collectionName, storyID string) (*models.Story, error) {
 	storyObjID, err := primitive.ObjectIDFromHex(storyID)
 	if err != nil {
 		return nil, err
 	}
-
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
+	if collectionName == "" {
+		collectionName = defaultStoryCollection
+	}
 
 	var story models.Story
-	collection := db.GetCollection("stories")
+	collection := db.GetCollection(collectionName)
 	if err := collection.FindOne(ctx, bson.M{"_id": storyObjID}).Decode(&story); err != nil {
 		return nil, err
 	}
 
+	return &story, nil
+}
+
+// resolveStoryCollection maps the "collection" query parameter to a configured story
+// collection. An empty parameter selects the default "stories" collection; anything
+// that is not an alias from config.GetStoryCollections is rejected.
+func resolveStoryCollection(r *http.Request) (string, bool) {
+	return storyCollectionForAlias(r.URL.Query().Get("collection"))
+}
+
+// storyCollectionForAlias is resolveStoryCollection for an alias sent in a request body
+func storyCollectionForAlias(alias string) (string, bool) {
+	if alias == "" {
+		return defaultStoryCollection, true
+	}
+	name, ok := config.GetStoryCollections()[alias]
+	return name, ok
+}
+
+// findCharacter returns the story character with the given ID, or nil
+func findCharacter(story *models.Story, characterID string) *models.Character {
+	for i := range story.Story.Characters {
+		if story.Story.Characters[i].ID == characterID {
+			return &story.Story.Characters[i]
+		}
+	}
+	return nil
+}
+
+// findLocation returns the story location with the given ID, or nil
+func findLocation(story *models.Story, locationID string) *models.Location {
+	for i := range story.Story.Locations {
+		if story.Story.Locations[i].ID == locationID {
+			return &story.Story.Locations[i]
+		}
+	}
+	return nil
+}
+
+// findStoryEvidence returns the evidence in the story matching the requested IDs,
+// searching both character holdings and location containers
+func findStoryEvidence(story *models.Story, evidenceIDs []string) []models.Evidence {
 	evidenceMap := make(map[string]bool, len(evidenceIDs))
 	for _, id := range evidenceIDs {
 		evidenceMap[id] = true
@@ -51,5 +104,23 @@ func fetchEvidenceDetails(storyID string, evidenceIDs []string) ([]models.Eviden
 		}
 	}
 
-	return evidenceDetails, nil
+	return evidenceDetails
+}
+
+// storyEvidenceIDs returns the IDs of every evidence item in the story
+func storyEvidenceIDs(story *models.Story) map[string]bool {
+	ids := map[string]bool{}
+	for _, character := range story.Story.Characters {
+		for _, evidence := range character.HoldsEvidence {
+			ids[evidence.ID] = true
+		}
+	}
+	for _, location := range story.Story.Locations {
+		for _, container := range location.Containers {
+			for _, evidence := range container.ContainsEvidence {
+				ids[evidence.ID] = true
+			}
+		}
+	}
+	return ids
 }
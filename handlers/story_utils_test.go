@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestResolveStoryCollection(t *testing.T) {
+	t.Setenv("STORY_COLLECTIONS", "stories, drafts=story_drafts ,spring=seasonal_spring")
+
+	tests := []struct {
+		query  string
+		want   string
+		wantOK bool
+	}{
+		{"", "stories", true},
+		{"?collection=stories", "stories", true},
+		{"?collection=drafts", "story_drafts", true},
+		{"?collection=spring", "seasonal_spring", true},
+		{"?collection=story_drafts", "", false}, // Only aliases are accepted
+		{"?collection=agents", "", false},
+		{"?collection=conversations", "", false},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/v2/feed"+tt.query, nil)
+		got, ok := resolveStoryCollection(r)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("resolveStoryCollection(%q) = %q, %v; want %q, %v", tt.query, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestResolveStoryCollectionDefaultsToStories(t *testing.T) {
+	t.Setenv("STORY_COLLECTIONS", "")
+
+	r := httptest.NewRequest("GET", "/v2/feed?collection=stories", nil)
+	if got, ok := resolveStoryCollection(r); !ok || got != "stories" {
+		t.Errorf("expected the stories collection by default, got %q, %v", got, ok)
+	}
+	r = httptest.NewRequest("GET", "/v2/feed?collection=agents", nil)
+	if _, ok := resolveStoryCollection(r); ok {
+		t.Error("expected agents to be rejected")
+	}
+}
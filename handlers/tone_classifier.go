@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"agent/agent"
+	"agent/llm"
+	"agent/metrics"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+)
+
+// ToneClassifier labels the tone of a player's message for the standing rules
+type ToneClassifier struct {
+	generator llm.Generator
+}
+
+// NewToneClassifier creates a classifier backed by the default LLM provider
+func NewToneClassifier() *ToneClassifier {
+	return &ToneClassifier{
+		generator: llm.Default(),
+	}
+}
+
+// ClassifyTone returns one of agent.Tones, falling back to neutral on any failure
+func (c *ToneClassifier) ClassifyTone(ctx context.Context, message string) string {
+	prompt := fmt.Sprintf(`
+	You are a tone classifier for a detective game. Classify how the investigator is speaking to the character.
+
+	Investigator's message:
+	"%s"
+
+	Choose exactly one tone:
+	- friendly: warm, sympathetic, building rapport
+	- respectful: polite and professional
+	- neutral: plain questions with no particular attitude
+	- rude: dismissive, insulting or mocking
+	- threatening: intimidation, threats of arrest or harm, aggressive pressure
+
+	Respond ONLY with JSON in the form {"tone": "<tone>"}`,
+		message,
+	)
+
+	responseText, err := c.generator.Generate(ctx, prompt, llm.Options{JSON: true, Purpose: llm.PurposeTone})
+	if err != nil {
+		log.Printf("[TONE_CLASSIFIER_ERROR] Failed to generate response: %v", err)
+		return agent.ToneNeutral
+	}
+
+	var result struct {
+		Tone string `json:"tone"`
+	}
+	if err := json.Unmarshal([]byte(responseText), &result); err != nil {
+		log.Printf("[TONE_CLASSIFIER_ERROR] Failed to parse LLM response: %v. Response was: %s", err, responseText)
+		metrics.JSONParseFailed(llm.PurposeTone)
+		return agent.ToneNeutral
+	}
+
+	tone := strings.ToLower(strings.TrimSpace(result.Tone))
+	if !slices.Contains(agent.Tones, tone) {
+		log.Printf("[TONE_CLASSIFIER_WARNING] LLM returned unknown tone: %s", result.Tone)
+		return agent.ToneNeutral
+	}
+
+	return tone
+}
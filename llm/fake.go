@@ -0,0 +1,77 @@
+package llm
+
+import (
+	"context"
+	"sync"
+)
+
+// FakeCall records a single call made to a Fake generator
+type FakeCall struct {
+	History []Message
+	Options Options
+}
+
+// Fake is a deterministic Generator for tests and offline development.
+// Responses are returned in order and the last one is repeated once the
+// queue is exhausted. If Respond is set it takes precedence.
+type Fake struct {
+	Responses []string
+	Respond   func(history []Message, opts Options) (string, error)
+
+	mu    sync.Mutex
+	next  int
+	calls []FakeCall
+}
+
+// NewFake creates a fake generator that replays the given responses
+func NewFake(responses ...string) *Fake {
+	return &Fake{Responses: responses}
+}
+
+// Generate implements Generator
+func (f *Fake) Generate(ctx context.Context, prompt string, opts Options) (string, error) {
+	return f.Chat(ctx, []Message{NewMessage(RoleUser, prompt)}, opts)
+}
+
+// Chat implements Generator
+func (f *Fake) Chat(ctx context.Context, history []Message, opts Options) (string, error) {
+	f.mu.Lock()
+	f.calls = append(f.calls, FakeCall{History: append([]Message(nil), history...), Options: opts})
+	respond := f.Respond
+	var response string
+	if len(f.Responses) > 0 {
+		response = f.Responses[min(f.next, len(f.Responses)-1)]
+		f.next++
+	} else if opts.JSON {
+		response = "{}"
+	}
+	f.mu.Unlock()
+
+	if respond != nil {
+		return respond(history, opts)
+	}
+	return response, nil
+}
+
+// fakeChunkSize is how many bytes of a response Fake streams per chunk
+const fakeChunkSize = 8
+
+// ChatStream implements Streamer by replaying the Chat response in small chunks
+func (f *Fake) ChatStream(ctx context.Context, history []Message, opts Options, onDelta StreamFunc) (string, error) {
+	response, err := f.Chat(ctx, history, opts)
+	if err != nil {
+		return "", err
+	}
+
+	for i := 0; i < len(response); i += fakeChunkSize {
+		onDelta(response[i:min(i+fakeChunkSize, len(response))])
+	}
+	return response, nil
+}
+
+// Calls returns a copy of every call made so far
+func (f *Fake) Calls() []FakeCall {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]FakeCall(nil), f.calls...)
+}
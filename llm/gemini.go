@@ -0,0 +1,104 @@
+package llm
+
+import (
+	"context"
+	"strings"
+
+	"agent/metrics"
+
+	"google.golang.org/genai"
+)
+
+// Gemini talks to Google's Gemini API through a single shared client
+type Gemini struct {
+	client *genai.Client
+	model  string
+}
+
+// NewGemini creates a Gemini generator for the given model
+func NewGemini(ctx context.Context, apiKey, model string) (*Gemini, error) {
+	client, err := genai.NewClient(ctx, &genai.ClientConfig{
+		APIKey: apiKey,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &Gemini{client: client, model: model}, nil
+}
+
+// Generate implements Generator
+func (g *Gemini) Generate(ctx context.Context, prompt string, opts Options) (string, error) {
+	return g.Chat(ctx, []Message{NewMessage(RoleUser, prompt)}, opts)
+}
+
+// Ping implements Pinger by looking up the configured model, which costs no tokens
+func (g *Gemini) Ping(ctx context.Context) error {
+	_, err := g.client.Models.Get(ctx, g.model, nil)
+	return err
+}
+
+// Chat implements Generator
+func (g *Gemini) Chat(ctx context.Context, history []Message, opts Options) (string, error) {
+	resp, err := g.client.Models.GenerateContent(ctx, g.model, toGeminiContents(history), geminiConfig(opts))
+	if err != nil {
+		return "", err
+	}
+	recordGeminiUsage(opts, resp.UsageMetadata)
+
+	return resp.Text(), nil
+}
+
+// ChatStream implements Streamer
+func (g *Gemini) ChatStream(ctx context.Context, history []Message, opts Options, onDelta StreamFunc) (string, error) {
+	var full strings.Builder
+	var usage *genai.GenerateContentResponseUsageMetadata
+	for resp, err := range g.client.Models.GenerateContentStream(ctx, g.model, toGeminiContents(history), geminiConfig(opts)) {
+		if err != nil {
+			return "", err
+		}
+		if resp.UsageMetadata != nil {
+			usage = resp.UsageMetadata
+		}
+		if delta := resp.Text(); delta != "" {
+			full.WriteString(delta)
+			onDelta(delta)
+		}
+	}
+	recordGeminiUsage(opts, usage)
+
+	return full.String(), nil
+}
+
+// recordGeminiUsage adds the reported token counts to the metrics. Thinking
+// tokens are billed as output, so they count as completion tokens.
+func recordGeminiUsage(opts Options, usage *genai.GenerateContentResponseUsageMetadata) {
+	if usage == nil {
+		return
+	}
+	metrics.AddLLMTokens(opts.purpose(), int(usage.PromptTokenCount), int(usage.CandidatesTokenCount+usage.ThoughtsTokenCount))
+}
+
+// geminiConfig maps generation options onto the genai request config
+func geminiConfig(opts Options) *genai.GenerateContentConfig {
+	if !opts.JSON {
+		return nil
+	}
+
+	return &genai.GenerateContentConfig{
+		ResponseMIMEType: "application/json",
+	}
+}
+
+// toGeminiContents converts provider-neutral history into genai contents
+func toGeminiContents(history []Message) []*genai.Content {
+	contents := make([]*genai.Content, 0, len(history))
+	for _, msg := range history {
+		var role genai.Role = genai.RoleModel
+		if msg.Role == RoleUser {
+			role = genai.RoleUser
+		}
+		contents = append(contents, genai.NewContentFromText(msg.Content, role))
+	}
+	return contents
+}
@@ -0,0 +1,50 @@
+package llm
+
+import (
+	"context"
+	"time"
+
+	"agent/metrics"
+)
+
+// instrumented records the count, outcome and latency of every call to gen
+type instrumented struct {
+	gen      Generator
+	provider string
+}
+
+// Instrument wraps gen so its calls show up in the LLM metrics, labelled with
+// the provider name and each call's Options.Purpose. Streaming and Ping are
+// passed through when gen supports them.
+func Instrument(gen Generator, provider string) Generator {
+	return &instrumented{gen: gen, provider: provider}
+}
+
+// Generate implements Generator
+func (i *instrumented) Generate(ctx context.Context, prompt string, opts Options) (string, error) {
+	start := time.Now()
+	text, err := i.gen.Generate(ctx, prompt, opts)
+	metrics.ObserveLLMCall(opts.purpose(), i.provider, time.Since(start), err)
+	return text, err
+}
+
+// Chat implements Generator
+func (i *instrumented) Chat(ctx context.Context, history []Message, opts Options) (string, error) {
+	start := time.Now()
+	text, err := i.gen.Chat(ctx, history, opts)
+	metrics.ObserveLLMCall(opts.purpose(), i.provider, time.Since(start), err)
+	return text, err
+}
+
+// ChatStream implements Streamer. The latency covers the whole stream.
+func (i *instrumented) ChatStream(ctx context.Context, history []Message, opts Options, onDelta StreamFunc) (string, error) {
+	start := time.Now()
+	text, err := ChatStream(ctx, i.gen, history, opts, onDelta)
+	metrics.ObserveLLMCall(opts.purpose(), i.provider, time.Since(start), err)
+	return text, err
+}
+
+// Ping implements Pinger
+func (i *instrumented) Ping(ctx context.Context) error {
+	return Ping(ctx, i.gen)
+}
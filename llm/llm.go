@@ -0,0 +1,106 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+
+	"agent/config"
+)
+
+// Roles used in conversation history
+const (
+	RoleUser  = "user"
+	RoleModel = "model"
+)
+
+// Message is a single provider-neutral conversation turn
+type Message struct {
+	Role    string
+	Content string
+}
+
+// NewMessage creates a message with the given role and text
+func NewMessage(role, content string) Message {
+	return Message{Role: role, Content: content}
+}
+
+// Purposes label LLM calls in metrics
+const (
+	PurposeChat            = "chat"
+	PurposeScoring         = "scoring"
+	PurposeRevealDetection = "reveal_detection"
+	PurposeTone            = "tone"
+	PurposeSummary         = "summary"
+	PurposeOther           = "other"
+)
+
+// Options tunes a single generation call
+type Options struct {
+	// JSON asks the provider to answer with a JSON document
+	JSON bool
+	// Purpose labels the call in metrics, one of the Purpose constants
+	Purpose string
+}
+
+// purpose returns the metrics label for the call
+func (o Options) purpose() string {
+	if o.Purpose == "" {
+		return PurposeOther
+	}
+	return o.Purpose
+}
+
+// Generator is implemented by every LLM provider the server can talk to
+type Generator interface {
+	// Generate returns a completion for a single user prompt
+	Generate(ctx context.Context, prompt string, opts Options) (string, error)
+	// Chat continues a conversation and returns the next model turn
+	Chat(ctx context.Context, history []Message, opts Options) (string, error)
+}
+
+var (
+	defaultGenerator Generator
+	defaultMu        sync.RWMutex
+)
+
+// Init builds the provider selected by LLM_PROVIDER and installs it as the default
+func Init(ctx context.Context) error {
+	var (
+		gen Generator
+		err error
+	)
+
+	switch provider := config.GetLLMProvider(); provider {
+	case "gemini":
+		gen, err = NewGemini(ctx, config.GetGeminiAPIKey(), config.GetGeminiModel())
+	case "openai":
+		gen = NewOpenAI(config.GetOpenAIBaseURL(), config.GetOpenAIAPIKey(), config.GetOpenAIModel())
+	case "fake":
+		gen = NewFake()
+	default:
+		return fmt.Errorf("unknown LLM provider %q", provider)
+	}
+	if err != nil {
+		return err
+	}
+
+	SetDefault(Instrument(gen, config.GetLLMProvider()))
+	log.Printf("[LLM] Using provider: %s", config.GetLLMProvider())
+	return nil
+}
+
+// SetDefault replaces the generator returned by Default
+func SetDefault(gen Generator) {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	defaultGenerator = gen
+}
+
+// Default returns the generator installed by Init or SetDefault
+func Default() Generator {
+	defaultMu.RLock()
+	defer defaultMu.RUnlock()
+	return defaultGenerator
+}
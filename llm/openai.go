@@ -0,0 +1,241 @@
+package llm
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"agent/metrics"
+)
+
+// OpenAI talks to any server implementing the OpenAI chat completions API,
+// such as a local llama.cpp, vLLM or Ollama instance
+type OpenAI struct {
+	baseURL    string
+	apiKey     string
+	model      string
+	httpClient *http.Client
+}
+
+// NewOpenAI creates an OpenAI-compatible generator. baseURL should include the
+// version prefix, e.g. "http://localhost:11434/v1"
+func NewOpenAI(baseURL, apiKey, model string) *OpenAI {
+	return &OpenAI{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		apiKey:     apiKey,
+		model:      model,
+		httpClient: &http.Client{Timeout: 120 * time.Second},
+	}
+}
+
+type openAIMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type openAIResponseFormat struct {
+	Type string `json:"type"`
+}
+
+type openAIRequest struct {
+	Model          string                `json:"model"`
+	Messages       []openAIMessage       `json:"messages"`
+	ResponseFormat *openAIResponseFormat `json:"response_format,omitempty"`
+	Stream         bool                  `json:"stream,omitempty"`
+}
+
+type openAIUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+}
+
+type openAIResponse struct {
+	Choices []struct {
+		Message openAIMessage `json:"message"`
+	} `json:"choices"`
+	Usage *openAIUsage `json:"usage,omitempty"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+// openAIStreamChunk is one server-sent event of a streamed completion. Servers
+// that report usage for streams send it in a final chunk without choices.
+type openAIStreamChunk struct {
+	Choices []struct {
+		Delta struct {
+			Content string `json:"content"`
+		} `json:"delta"`
+	} `json:"choices"`
+	Usage *openAIUsage `json:"usage,omitempty"`
+}
+
+// Generate implements Generator
+func (o *OpenAI) Generate(ctx context.Context, prompt string, opts Options) (string, error) {
+	return o.Chat(ctx, []Message{NewMessage(RoleUser, prompt)}, opts)
+}
+
+// Chat implements Generator
+func (o *OpenAI) Chat(ctx context.Context, history []Message, opts Options) (string, error) {
+	req, err := o.newChatRequest(ctx, history, opts, false)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := o.httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+
+	var parsed openAIResponse
+	if err := json.Unmarshal(body, &parsed); err != nil {
+		return "", fmt.Errorf("openai: status %d: invalid response body: %w", resp.StatusCode, err)
+	}
+	if parsed.Error != nil {
+		return "", fmt.Errorf("openai: status %d: %s", resp.StatusCode, parsed.Error.Message)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("openai: unexpected status %d", resp.StatusCode)
+	}
+	if len(parsed.Choices) == 0 {
+		return "", errors.New("openai: response contained no choices")
+	}
+	recordOpenAIUsage(opts, parsed.Usage)
+
+	return parsed.Choices[0].Message.Content, nil
+}
+
+// ChatStream implements Streamer using the server-sent events of a streamed completion
+func (o *OpenAI) ChatStream(ctx context.Context, history []Message, opts Options, onDelta StreamFunc) (string, error) {
+	req, err := o.newChatRequest(ctx, history, opts, true)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := o.httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		var parsed openAIResponse
+		if json.Unmarshal(body, &parsed) == nil && parsed.Error != nil {
+			return "", fmt.Errorf("openai: status %d: %s", resp.StatusCode, parsed.Error.Message)
+		}
+		return "", fmt.Errorf("openai: unexpected status %d", resp.StatusCode)
+	}
+
+	var full strings.Builder
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data:")
+		if !ok {
+			continue
+		}
+		data = strings.TrimSpace(data)
+		if data == "[DONE]" {
+			break
+		}
+
+		var chunk openAIStreamChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return "", fmt.Errorf("openai: invalid stream chunk: %w", err)
+		}
+		recordOpenAIUsage(opts, chunk.Usage)
+		if len(chunk.Choices) == 0 || chunk.Choices[0].Delta.Content == "" {
+			continue
+		}
+		full.WriteString(chunk.Choices[0].Delta.Content)
+		onDelta(chunk.Choices[0].Delta.Content)
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+
+	return full.String(), nil
+}
+
+// Ping implements Pinger by listing the server's models, which costs no tokens
+func (o *OpenAI) Ping(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, o.baseURL+"/models", nil)
+	if err != nil {
+		return err
+	}
+	if o.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+o.apiKey)
+	}
+
+	resp, err := o.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("openai: unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// recordOpenAIUsage adds the reported token counts to the metrics
+func recordOpenAIUsage(opts Options, usage *openAIUsage) {
+	if usage == nil {
+		return
+	}
+	metrics.AddLLMTokens(opts.purpose(), usage.PromptTokens, usage.CompletionTokens)
+}
+
+// newChatRequest builds a chat completions request for the conversation
+func (o *OpenAI) newChatRequest(ctx context.Context, history []Message, opts Options, stream bool) (*http.Request, error) {
+	reqBody := openAIRequest{
+		Model:    o.model,
+		Messages: make([]openAIMessage, 0, len(history)),
+		Stream:   stream,
+	}
+	for i, msg := range history {
+		role := "assistant"
+		switch {
+		case msg.Role == RoleUser:
+			role = "user"
+		case i == 0:
+			// Agent histories open with the character instructions as a model turn
+			role = "system"
+		}
+		reqBody.Messages = append(reqBody.Messages, openAIMessage{Role: role, Content: msg.Content})
+	}
+	if opts.JSON {
+		reqBody.ResponseFormat = &openAIResponseFormat{Type: "json_object"}
+	}
+
+	payload, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, o.baseURL+"/chat/completions", bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if o.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+o.apiKey)
+	}
+	return req, nil
+}
@@ -0,0 +1,166 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestOpenAIChat(t *testing.T) {
+	var got openAIRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			t.Errorf("missing bearer token")
+		}
+		json.NewDecoder(r.Body).Decode(&got)
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"{\"reply\":\"Go away.\"}"}}]}`))
+	}))
+	defer server.Close()
+
+	gen := NewOpenAI(server.URL+"/v1/", "secret", "local-model")
+	history := []Message{
+		NewMessage(RoleModel, "You are Agnes."),
+		NewMessage(RoleUser, "Where were you last night?"),
+	}
+
+	reply, err := gen.Chat(context.Background(), history, Options{JSON: true})
+	if err != nil {
+		t.Fatalf("Chat returned error: %v", err)
+	}
+	if reply != `{"reply":"Go away."}` {
+		t.Errorf("unexpected reply %q", reply)
+	}
+
+	if got.Model != "local-model" {
+		t.Errorf("expected model local-model, got %s", got.Model)
+	}
+	if len(got.Messages) != 2 || got.Messages[0].Role != "system" || got.Messages[1].Role != "user" {
+		t.Errorf("unexpected messages %+v", got.Messages)
+	}
+	if got.ResponseFormat == nil || got.ResponseFormat.Type != "json_object" {
+		t.Error("expected json_object response format")
+	}
+}
+
+func TestOpenAIChatError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write([]byte(`{"error":{"message":"rate limited"}}`))
+	}))
+	defer server.Close()
+
+	_, err := NewOpenAI(server.URL, "", "m").Generate(context.Background(), "hi", Options{})
+	if err == nil {
+		t.Fatal("expected an error for a 429 response")
+	}
+}
+
+func TestOpenAIChatStream(t *testing.T) {
+	var got openAIRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&got)
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, chunk := range []string{
+			`{"choices":[{"delta":{"role":"assistant"}}]}`,
+			`{"choices":[{"delta":{"content":"{\"reply\":"}}]}`,
+			`{"choices":[{"delta":{"content":"\"Go away.\"}"}}]}`,
+			`[DONE]`,
+		} {
+			w.Write([]byte("data: " + chunk + "\n\n"))
+		}
+	}))
+	defer server.Close()
+
+	gen := NewOpenAI(server.URL, "", "local-model")
+	var deltas []string
+	reply, err := gen.ChatStream(context.Background(), []Message{NewMessage(RoleUser, "Hi")}, Options{JSON: true},
+		func(delta string) { deltas = append(deltas, delta) })
+	if err != nil {
+		t.Fatalf("ChatStream returned error: %v", err)
+	}
+
+	if !got.Stream {
+		t.Error("expected a streamed request")
+	}
+	if reply != `{"reply":"Go away."}` {
+		t.Errorf("unexpected reply %q", reply)
+	}
+	if len(deltas) != 2 {
+		t.Errorf("expected 2 deltas, got %q", deltas)
+	}
+}
+
+func TestChatStreamFallsBackToChat(t *testing.T) {
+	var deltas []string
+	reply, err := ChatStream(context.Background(), chatOnly{NewFake("whole reply")}, nil, Options{},
+		func(delta string) { deltas = append(deltas, delta) })
+	if err != nil || reply != "whole reply" || len(deltas) != 1 || deltas[0] != "whole reply" {
+		t.Errorf("unexpected fallback result %q %q %v", reply, deltas, err)
+	}
+}
+
+// chatOnly hides the Streamer implementation of the wrapped generator
+type chatOnly struct{ Generator }
+
+func TestOpenAIPing(t *testing.T) {
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || r.URL.Path != "/v1/models" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		w.WriteHeader(status)
+		w.Write([]byte(`{"data":[{"id":"local-model"}]}`))
+	}))
+	defer server.Close()
+
+	gen := NewOpenAI(server.URL+"/v1", "", "local-model")
+	if err := Ping(context.Background(), gen); err != nil {
+		t.Errorf("Ping returned error: %v", err)
+	}
+
+	status = http.StatusUnauthorized
+	if err := Ping(context.Background(), gen); err == nil {
+		t.Error("expected an error for a 401 response")
+	}
+}
+
+func TestOpenAIRequestRoles(t *testing.T) {
+	gen := NewOpenAI("http://localhost/v1", "", "m")
+	history := []Message{
+		NewMessage(RoleModel, "You are Agnes."),
+		NewMessage(RoleUser, "Where were you?"),
+		NewMessage(RoleModel, "Home."),
+		NewMessage(RoleUser, "Alone?"),
+	}
+
+	req, err := gen.newChatRequest(context.Background(), history, Options{}, false)
+	if err != nil {
+		t.Fatalf("newChatRequest returned error: %v", err)
+	}
+	var body openAIRequest
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		t.Fatalf("invalid request body: %v", err)
+	}
+
+	want := []string{"system", "user", "assistant", "user"}
+	if len(body.Messages) != len(want) {
+		t.Fatalf("got %d messages, want %d", len(body.Messages), len(want))
+	}
+	for i, role := range want {
+		if body.Messages[i].Role != role {
+			t.Errorf("message %d role = %q, want %q", i, body.Messages[i].Role, role)
+		}
+	}
+
+	// A single prompt from Generate is a user turn, not instructions
+	req, _ = gen.newChatRequest(context.Background(), []Message{NewMessage(RoleUser, "hi")}, Options{}, false)
+	json.NewDecoder(req.Body).Decode(&body)
+	if body.Messages[0].Role != "user" {
+		t.Errorf("Generate prompt role = %q, want user", body.Messages[0].Role)
+	}
+}
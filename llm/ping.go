@@ -0,0 +1,19 @@
+package llm
+
+import "context"
+
+// Pinger is implemented by generators that can check the provider is reachable
+// without generating anything
+type Pinger interface {
+	// Ping returns an error if the provider can't serve the configured model
+	Ping(ctx context.Context) error
+}
+
+// Ping checks that gen's provider is reachable if it implements Pinger.
+// Other generators are assumed to be reachable.
+func Ping(ctx context.Context, gen Generator) error {
+	if pinger, ok := gen.(Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
@@ -0,0 +1,28 @@
+package llm
+
+import "context"
+
+// StreamFunc receives each chunk of text as the model produces it
+type StreamFunc func(delta string)
+
+// Streamer is implemented by generators that can stream a chat reply
+type Streamer interface {
+	// ChatStream continues a conversation, calling onDelta for each chunk of the
+	// next model turn, and returns the complete turn once the stream ends
+	ChatStream(ctx context.Context, history []Message, opts Options, onDelta StreamFunc) (string, error)
+}
+
+// ChatStream streams the next model turn from gen if it implements Streamer.
+// Other generators answer in one piece, delivered to onDelta as a single chunk.
+func ChatStream(ctx context.Context, gen Generator, history []Message, opts Options, onDelta StreamFunc) (string, error) {
+	if streamer, ok := gen.(Streamer); ok {
+		return streamer.ChatStream(ctx, history, opts, onDelta)
+	}
+
+	text, err := gen.Chat(ctx, history, opts)
+	if err != nil {
+		return "", err
+	}
+	onDelta(text)
+	return text, nil
+}
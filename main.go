@@ -1,14 +1,22 @@
 package main
 
 import (
-	"fmt"
+	"context"
+	"errors"
 	"log"
-	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
+	"agent/agent"
+	"agent/auth"
+	"agent/config"
 	"agent/db"
-	"agent/handlers"
-	"agent/middleware"
+	"agent/llm"
+	"agent/metrics"
+	"agent/ratelimit"
+	"agent/server"
 	"github.com/joho/godotenv"
 )
 
@@ -32,19 +40,81 @@ func main() {
 		log.Println("No GEMINI_MODEL specified, defaulting to gemini-2.5-flash")
 	}
 
+	// Initialize the LLM provider shared by all handlers
+	if err := llm.Init(context.Background()); err != nil {
+		log.Fatal("Failed to initialize LLM provider:", err)
+	}
+
+	// Verify player tokens when AUTH_HMAC_SECRET or AUTH_JWKS_FILE is set
+	if err := auth.Init(); err != nil {
+		log.Fatal("Failed to initialize authentication:", err)
+	}
+
+	// Throttle the LLM-backed routes per player or client IP
+	if err := ratelimit.Init(); err != nil {
+		log.Fatal("Failed to initialize rate limiting:", err)
+	}
+
+	// Bound the in-memory agent registry and evict idle agents in the background
+	agent.InitRegistry(config.GetAgentRegistryMaxSize(), config.GetAgentRegistryIdleTTL())
+	stopJanitor := agent.AgentRegistry.StartJanitor(time.Minute)
+	defer stopJanitor()
+	metrics.RegisterAgentRegistry(func() metrics.AgentRegistryStats {
+		stats := agent.AgentRegistry.Stats()
+		return metrics.AgentRegistryStats{
+			Size:      stats.Size,
+			Hits:      stats.Hits,
+			Misses:    stats.Misses,
+			Evictions: stats.Evictions,
+		}
+	})
+
 	// Create database indexes
 	db.CreateAgentIndexes()
+	db.CreateContainerIndexes()
+	db.CreateSessionIndexes()
+	db.CreateScoreIndexes()
+	db.CreateRateLimitIndexes()
+	storyCollections := []string{"stories"}
+	for _, name := range config.GetStoryCollections() {
+		if name != "stories" {
+			storyCollections = append(storyCollections, name)
+		}
+	}
+	db.CreateStoryIndexes(storyCollections...)
+
+	// Warm the registry with recently active agents without blocking startup
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+		defer cancel()
+
+		agent.PreloadActiveAgents(ctx, agent.PreloadConfig{
+			Window:  config.GetAgentPreloadWindow(),
+			Limit:   config.GetAgentPreloadLimit(),
+			Workers: config.GetAgentPreloadWorkers(),
+		}, func(p agent.PreloadProgress) {
+			if p.Done()%10 == 0 || p.Done() == p.Total {
+				log.Printf("[AGENT_PRELOAD] Progress %d/%d (loaded=%d skipped=%d failed=%d)",
+					p.Done(), p.Total, p.Loaded, p.Skipped, p.Failed)
+			}
+		})
+	}()
+
+	// Serve until SIGINT or SIGTERM, then let in-flight requests finish
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	srv := server.New(server.ConfigFromEnv(), server.Handler())
+	if err := srv.Run(ctx); errors.Is(err, server.ErrShutdownIncomplete) {
+		// Handlers may still be writing; flushing or closing the database under them could lose their writes
+		log.Printf("[SERVER_ERROR] %v, exiting without flushing agents", err)
+		os.Exit(1)
+	} else if err != nil {
+		log.Printf("Server error: %v", err)
+	}
 
-	// Set up HTTP handlers with CORS
-	http.HandleFunc("/agent/history", middleware.EnableCORS(handlers.HistoryHandler))
-	http.HandleFunc("/score", middleware.EnableCORS(handlers.ScoreTheoryHandler))
-	http.HandleFunc("/feed", middleware.EnableCORS(handlers.FeedHandler))
-	http.HandleFunc("/story", middleware.EnableCORS(handlers.StoryDetailHandler))
-	http.HandleFunc("/stories/", middleware.EnableCORS(handlers.StoryDetailRESTHandler)) // RESTful route
-	http.HandleFunc("/v2/feed", middleware.EnableCORS(handlers.FeedHandlerV2))
-	http.HandleFunc("/v2/story", middleware.EnableCORS(handlers.StoryDetailHandlerV2))
-	//http.HandleFunc("/delete", middleware.EnableCORS(handlers.DeleteAgentHandler))
-
-	fmt.Println("Server running on http://localhost:8080")
-	log.Fatal(http.ListenAndServe(":8080", nil))
+	// Persist every loaded agent before the deferred db.Close
+	log.Printf("[SERVER] Flushing %d agents", agent.AgentRegistry.Len())
+	agent.AgentRegistry.Flush()
+	log.Println("[SERVER] Shutdown complete")
 }
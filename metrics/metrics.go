@@ -0,0 +1,120 @@
+// Package metrics defines the Prometheus metrics the server exposes on /metrics.
+// Other packages record through the helpers here so label names stay consistent.
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "case_api"
+
+// Outcomes recorded for LLM calls and Mongo commands
+const (
+	OutcomeOK    = "ok"
+	OutcomeError = "error"
+)
+
+var (
+	httpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by route pattern, method and status code.",
+	}, []string{"route", "method", "status"})
+
+	httpDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by route pattern and method. WebSocket connections are not observed.",
+		Buckets:   []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 20, 40, 60},
+	}, []string{"route", "method"})
+
+	llmCalls = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "llm_calls_total",
+		Help:      "LLM calls by purpose, provider and outcome.",
+	}, []string{"purpose", "provider", "outcome"})
+
+	llmDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "llm_call_duration_seconds",
+		Help:      "LLM call latency by purpose and provider.",
+		Buckets:   []float64{.25, .5, 1, 2, 4, 8, 15, 30, 60},
+	}, []string{"purpose", "provider"})
+
+	llmTokens = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "llm_tokens_total",
+		Help:      "Tokens reported by the LLM provider by purpose and direction (prompt or completion).",
+	}, []string{"purpose", "direction"})
+
+	jsonParseFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "llm_json_parse_failures_total",
+		Help:      "Model answers that should have been JSON but could not be parsed, by purpose.",
+	}, []string{"purpose"})
+
+	mongoDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "mongo_command_duration_seconds",
+		Help:      "MongoDB command latency by command name and outcome.",
+		Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"command", "outcome"})
+)
+
+// Handler serves the metrics in the Prometheus text format
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// ObserveHTTPRequest records a finished HTTP request. route is the pattern the
+// request matched, so paths with IDs don't each get their own series.
+// Pass a zero duration to count the request without observing its latency.
+func ObserveHTTPRequest(route, method string, status int, d time.Duration) {
+	if route == "" {
+		route = "unmatched"
+	}
+	httpRequests.WithLabelValues(route, method, strconv.Itoa(status)).Inc()
+	if d > 0 {
+		httpDuration.WithLabelValues(route, method).Observe(d.Seconds())
+	}
+}
+
+// ObserveLLMCall records one call to the LLM provider
+func ObserveLLMCall(purpose, provider string, d time.Duration, err error) {
+	outcome := OutcomeOK
+	if err != nil {
+		outcome = OutcomeError
+	}
+	llmCalls.WithLabelValues(purpose, provider, outcome).Inc()
+	llmDuration.WithLabelValues(purpose, provider).Observe(d.Seconds())
+}
+
+// AddLLMTokens records the token usage a provider reported for one call
+func AddLLMTokens(purpose string, prompt, completion int) {
+	if prompt > 0 {
+		llmTokens.WithLabelValues(purpose, "prompt").Add(float64(prompt))
+	}
+	if completion > 0 {
+		llmTokens.WithLabelValues(purpose, "completion").Add(float64(completion))
+	}
+}
+
+// JSONParseFailed records a model answer that could not be parsed as the JSON asked for
+func JSONParseFailed(purpose string) {
+	jsonParseFailures.WithLabelValues(purpose).Inc()
+}
+
+// ObserveMongoCommand records one MongoDB command
+func ObserveMongoCommand(command string, d time.Duration, failed bool) {
+	outcome := OutcomeOK
+	if failed {
+		outcome = OutcomeError
+	}
+	mongoDuration.WithLabelValues(command, outcome).Observe(d.Seconds())
+}
@@ -0,0 +1,50 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// AgentRegistryStats is what the agent registry reports for /metrics
+type AgentRegistryStats struct {
+	Size      int
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+}
+
+// RegisterAgentRegistry exposes the agent registry's size and counters, read
+// from stats whenever metrics are scraped. Call it once at startup.
+func RegisterAgentRegistry(stats func() AgentRegistryStats) {
+	prometheus.MustRegister(agentRegistryCollector{stats: stats})
+}
+
+var (
+	agentRegistrySizeDesc = prometheus.NewDesc(namespace+"_agent_registry_size",
+		"Agents currently loaded in memory.", nil, nil)
+	agentRegistryHitsDesc = prometheus.NewDesc(namespace+"_agent_registry_hits_total",
+		"Agent lookups served from memory.", nil, nil)
+	agentRegistryMissesDesc = prometheus.NewDesc(namespace+"_agent_registry_misses_total",
+		"Agent lookups that had to load from the database.", nil, nil)
+	agentRegistryEvictionsDesc = prometheus.NewDesc(namespace+"_agent_registry_evictions_total",
+		"Agents evicted for size or idleness.", nil, nil)
+)
+
+// agentRegistryCollector reads one stats snapshot per scrape so the values are consistent
+type agentRegistryCollector struct {
+	stats func() AgentRegistryStats
+}
+
+func (c agentRegistryCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- agentRegistrySizeDesc
+	ch <- agentRegistryHitsDesc
+	ch <- agentRegistryMissesDesc
+	ch <- agentRegistryEvictionsDesc
+}
+
+func (c agentRegistryCollector) Collect(ch chan<- prometheus.Metric) {
+	s := c.stats()
+	ch <- prometheus.MustNewConstMetric(agentRegistrySizeDesc, prometheus.GaugeValue, float64(s.Size))
+	ch <- prometheus.MustNewConstMetric(agentRegistryHitsDesc, prometheus.CounterValue, float64(s.Hits))
+	ch <- prometheus.MustNewConstMetric(agentRegistryMissesDesc, prometheus.CounterValue, float64(s.Misses))
+	ch <- prometheus.MustNewConstMetric(agentRegistryEvictionsDesc, prometheus.CounterValue, float64(s.Evictions))
+}
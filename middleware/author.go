@@ -0,0 +1,24 @@
+package middleware
+
+import (
+	"agent/config"
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+// RequireAuthorKey only lets requests through that carry the author API key
+// as "Authorization: Bearer <key>"
+func RequireAuthorKey(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := config.GetAuthorAPIKey()
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if key == "" || !ok || subtle.ConstantTimeCompare([]byte(key), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		next(w, r)
+	}
+}
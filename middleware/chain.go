@@ -0,0 +1,15 @@
+package middleware
+
+import "net/http"
+
+// Middleware wraps a handler with extra behaviour
+type Middleware func(next http.HandlerFunc) http.HandlerFunc
+
+// Chain wraps next in the given middleware. The first middleware is the
+// outermost, so it sees the request first and the response last.
+func Chain(next http.HandlerFunc, middleware ...Middleware) http.HandlerFunc {
+	for i := len(middleware) - 1; i >= 0; i-- {
+		next = middleware[i](next)
+	}
+	return next
+}
@@ -3,27 +3,13 @@ package middleware
 import (
 	"net/http"
 	"os"
+	"slices"
 	"strings"
 )
 
 // EnableCORS adds CORS headers to responses
 func EnableCORS(next http.HandlerFunc) http.HandlerFunc {
-	// Get allowed origins from environment variable
-	// Example: ALLOWED_ORIGINS="http://localhost:3000,http://localhost:5173,https://myapp.com"
-	allowedOriginsEnv := os.Getenv("ALLOWED_ORIGINS")
-
-	// Default allowed origins if not set
-	var allowedOrigins []string
-	if allowedOriginsEnv != "" {
-		allowedOrigins = strings.Split(allowedOriginsEnv, ",")
-		// Trim whitespace from each origin
-		for i := range allowedOrigins {
-			allowedOrigins[i] = strings.TrimSpace(allowedOrigins[i])
-		}
-	} else {
-		// Default for development if env var not set
-		allowedOrigins = []string{"http://localhost:5173", "http://localhost:3000"}
-	}
+	allowedOrigins := allowedOrigins()
 
 	return func(w http.ResponseWriter, r *http.Request) {
 		origin := r.Header.Get("Origin")
@@ -61,3 +47,33 @@ func EnableCORS(next http.HandlerFunc) http.HandlerFunc {
 		next(w, r)
 	}
 }
+
+// allowedOrigins returns the origins allowed to call the API
+func allowedOrigins() []string {
+	// Get allowed origins from environment variable
+	// Example: ALLOWED_ORIGINS="http://localhost:3000,http://localhost:5173,https://myapp.com"
+	allowedOriginsEnv := os.Getenv("ALLOWED_ORIGINS")
+
+	// Default allowed origins if not set
+	var allowedOrigins []string
+	if allowedOriginsEnv != "" {
+		allowedOrigins = strings.Split(allowedOriginsEnv, ",")
+		// Trim whitespace from each origin
+		for i := range allowedOrigins {
+			allowedOrigins[i] = strings.TrimSpace(allowedOrigins[i])
+		}
+	} else {
+		// Default for development if env var not set
+		allowedOrigins = []string{"http://localhost:5173", "http://localhost:3000"}
+	}
+	return allowedOrigins
+}
+
+// OriginAllowed reports whether a browser origin may use the API under the same
+// rules as EnableCORS. WebSocket upgrades aren't covered by CORS, so they check this.
+func OriginAllowed(origin string) bool {
+	if os.Getenv("CORS_ALLOW_ALL") == "true" {
+		return true
+	}
+	return slices.Contains(allowedOrigins(), origin)
+}
@@ -0,0 +1,89 @@
+package middleware
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"time"
+)
+
+// LogRequests logs the method, path, status, size and duration of every request
+func LogRequests(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &responseRecorder{ResponseWriter: w}
+
+		next(rec, r)
+
+		log.Printf("[HTTP] %s %s %s %d %dB %s",
+			GetRequestID(r.Context()), r.Method, r.URL.Path, rec.Status(), rec.bytes, time.Since(start).Round(time.Millisecond))
+	}
+}
+
+// responseRecorder records the status and size of a response. It passes
+// Flush and Hijack through so Server-Sent Events and WebSocket upgrades keep
+// working behind it, and Unwrap lets http.ResponseController reach the
+// underlying writer.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (rec *responseRecorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *responseRecorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	n, err := rec.ResponseWriter.Write(b)
+	rec.bytes += n
+	return n, err
+}
+
+func (rec *responseRecorder) Flush() {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	if flusher, ok := rec.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (rec *responseRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := rec.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("response writer does not support hijacking")
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err == nil {
+		rec.status = http.StatusSwitchingProtocols
+	}
+	return conn, rw, err
+}
+
+func (rec *responseRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+// Status returns the response status, or 200 if the handler wrote nothing
+func (rec *responseRecorder) Status() int {
+	if rec.status == 0 {
+		return http.StatusOK
+	}
+	return rec.status
+}
+
+// headerWritten reports whether the response has been started, when w is
+// wrapped by LogRequests
+func headerWritten(w http.ResponseWriter) bool {
+	rec, ok := w.(*responseRecorder)
+	return ok && rec.status != 0
+}
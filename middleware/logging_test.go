@@ -0,0 +1,77 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestLogRequestsKeepsFlusherAndHijacker(t *testing.T) {
+	var flushed, hijackable bool
+	handler := LogRequests(func(w http.ResponseWriter, r *http.Request) {
+		_, hijackable = w.(http.Hijacker)
+		if f, ok := w.(http.Flusher); ok {
+			f.Flush()
+			flushed = true
+		}
+	})
+
+	rr := httptest.NewRecorder()
+	handler(rr, httptest.NewRequest("GET", "/message/stream", nil))
+
+	if !flushed || !rr.Flushed {
+		t.Error("Flush was not passed through to the underlying writer")
+	}
+	if !hijackable {
+		t.Error("wrapped writer does not implement http.Hijacker")
+	}
+	if err := http.NewResponseController(&responseRecorder{ResponseWriter: rr}).Flush(); err != nil {
+		t.Errorf("ResponseController could not reach the underlying writer: %v", err)
+	}
+}
+
+func TestRecoverWritesServerError(t *testing.T) {
+	handler := Chain(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}, RequestID, LogRequests, Recover)
+
+	rr := httptest.NewRecorder()
+	handler(rr, httptest.NewRequest("GET", "/feed", nil))
+
+	if rr.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, want %d", rr.Code, http.StatusInternalServerError)
+	}
+	if rr.Header().Get(RequestIDHeader) == "" {
+		t.Error("response has no request ID")
+	}
+}
+
+func TestRequestIDReusesSaneHeader(t *testing.T) {
+	var got string
+	handler := RequestID(func(w http.ResponseWriter, r *http.Request) {
+		got = GetRequestID(r.Context())
+	})
+
+	tests := []struct {
+		header string
+		reused bool
+	}{
+		{"abc-123", true},
+		{"", false},
+		{"has space", false},
+		{string(make([]byte, maxRequestIDLength+1)), false},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set(RequestIDHeader, tt.header)
+		rr := httptest.NewRecorder()
+		handler(rr, r)
+
+		if (got == tt.header) != tt.reused || got == "" {
+			t.Errorf("header %q: request ID = %q, reused want %v", tt.header, got, tt.reused)
+		}
+		if rr.Header().Get(RequestIDHeader) != got {
+			t.Errorf("header %q: response ID %q does not match %q", tt.header, rr.Header().Get(RequestIDHeader), got)
+		}
+	}
+}
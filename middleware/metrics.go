@@ -0,0 +1,29 @@
+package middleware
+
+import (
+	"agent/metrics"
+	"net/http"
+	"time"
+)
+
+// RecordMetrics counts every request and observes its latency by the route
+// pattern it matched. It goes outside the router, which sets r.Pattern, and
+// inside LogRequests so both share one response recorder. WebSocket upgrades
+// are counted but their connection time isn't observed as latency.
+func RecordMetrics(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec, ok := w.(*responseRecorder)
+		if !ok {
+			rec = &responseRecorder{ResponseWriter: w}
+		}
+
+		next(rec, r)
+
+		duration := time.Since(start)
+		if rec.Status() == http.StatusSwitchingProtocols {
+			duration = 0
+		}
+		metrics.ObserveHTTPRequest(r.Pattern, r.Method, rec.Status(), duration)
+	}
+}
@@ -0,0 +1,43 @@
+package middleware
+
+import (
+	"agent/auth"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// RequirePlayer only lets requests through that carry a valid player token as
+// "Authorization: Bearer <token>", and adds the token's player ID to the request
+// context. Browsers can't set headers on WebSocket upgrades, so those may pass
+// the token as an access_token query parameter instead. When authentication is
+// disabled, requests pass through without a player ID.
+func RequirePlayer(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		verifier := auth.Default()
+		if verifier == nil {
+			next(w, r)
+			return
+		}
+
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok && strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+			token = r.URL.Query().Get("access_token")
+		}
+		if token == "" {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		playerID, err := verifier.Verify(token)
+		if err != nil {
+			log.Printf("[AUTH] Rejected token for %s %s: %v", r.Method, r.URL.Path, err)
+			w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		next(w, r.WithContext(auth.WithPlayerID(r.Context(), playerID)))
+	}
+}
@@ -0,0 +1,80 @@
+package middleware
+
+import (
+	"agent/auth"
+	"agent/config"
+	"agent/ratelimit"
+	"context"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RateLimit only lets requests through while the client has budget left for the
+// route, answering 429 with Retry-After otherwise. Clients are told apart by
+// player ID, or by IP address when the request is anonymous, so it goes inside
+// RequirePlayer on player routes. If the limiter's store fails, requests are let
+// through rather than taking the API down with it.
+func RateLimit(route string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		limiter := ratelimit.Default()
+		if limiter == nil || r.Method == http.MethodOptions {
+			next(w, r)
+			return
+		}
+
+		client := "ip:" + clientIP(r)
+		if player := auth.PlayerID(r.Context()); player != "" {
+			client = "player:" + player
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+		decision, err := limiter.Allow(ctx, route, client)
+		cancel()
+		if err != nil {
+			log.Printf("[RATE_LIMIT_ERROR] Failed to check %s budget for %s: %v", route, client, err)
+			next(w, r)
+			return
+		}
+
+		if !decision.Allowed {
+			seconds := max(1, int(math.Ceil(decision.RetryAfter.Seconds())))
+			log.Printf("[RATE_LIMIT] %s is over the %s budget, retry in %ds", client, route, seconds)
+			w.Header().Set("Retry-After", strconv.Itoa(seconds))
+			http.Error(w, "Too many requests", http.StatusTooManyRequests)
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+// clientIP returns the address of the client, from X-Forwarded-For when the
+// server is configured to trust its proxies. Each proxy appends the address it
+// received from, so the entry the outermost trusted proxy added is counted from
+// the right; anything left of it came from the client and can be forged.
+func clientIP(r *http.Request) string {
+	if config.GetTrustProxyHeaders() {
+		var hops []string
+		for _, header := range r.Header.Values("X-Forwarded-For") {
+			for hop := range strings.SplitSeq(header, ",") {
+				if hop = strings.TrimSpace(hop); hop != "" {
+					hops = append(hops, hop)
+				}
+			}
+		}
+		if len(hops) > 0 {
+			return hops[max(0, len(hops)-config.GetTrustedProxyHops())]
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
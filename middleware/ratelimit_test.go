@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIPUsesTrustedHop(t *testing.T) {
+	tests := []struct {
+		name      string
+		trust     string
+		hops      string
+		forwarded []string
+		want      string
+	}{
+		{"proxy headers ignored", "", "", []string{"203.0.113.7"}, "192.0.2.1"},
+		{"no header", "true", "", nil, "192.0.2.1"},
+		{"single proxy", "true", "", []string{"203.0.113.7"}, "203.0.113.7"},
+		{"spoofed entry skipped", "true", "", []string{"10.0.0.1, 203.0.113.7"}, "203.0.113.7"},
+		{"two proxies", "true", "2", []string{"10.0.0.1, 203.0.113.7, 198.51.100.4"}, "203.0.113.7"},
+		{"repeated headers", "true", "2", []string{"10.0.0.1", "203.0.113.7, 198.51.100.4"}, "203.0.113.7"},
+		{"fewer entries than hops", "true", "3", []string{"203.0.113.7, 198.51.100.4"}, "203.0.113.7"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("TRUST_PROXY_HEADERS", tt.trust)
+			t.Setenv("TRUSTED_PROXY_HOPS", tt.hops)
+
+			r := httptest.NewRequest("GET", "/message", nil)
+			r.RemoteAddr = "192.0.2.1:4321"
+			for _, value := range tt.forwarded {
+				r.Header.Add("X-Forwarded-For", value)
+			}
+
+			if got := clientIP(r); got != tt.want {
+				t.Errorf("clientIP = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
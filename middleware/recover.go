@@ -0,0 +1,35 @@
+package middleware
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"runtime/debug"
+)
+
+// Recover turns a panicking handler into a 500 response instead of a dropped
+// connection, and logs the panic with its stack. It relies on LogRequests
+// running outside it to know whether the response was already started.
+func Recover(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			err := recover()
+			if err == nil {
+				return
+			}
+			if err == http.ErrAbortHandler {
+				// Deliberate abort, let net/http close the connection quietly
+				panic(err)
+			}
+
+			log.Printf("[PANIC] %s %s %s: %v\n%s", GetRequestID(r.Context()), r.Method, r.URL.Path, err, debug.Stack())
+			if !headerWritten(w) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusInternalServerError)
+				json.NewEncoder(w).Encode(map[string]string{"error": "Internal server error"})
+			}
+		}()
+
+		next(w, r)
+	}
+}
@@ -0,0 +1,121 @@
+package models
+
+import (
+	"slices"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// PublicStory is the player-facing view of a story. It only carries what a
+// player may see when starting the case: no solution, no character knowledge,
+// no evidence and no container codes.
+type PublicStory struct {
+	ID        primitive.ObjectID `json:"id"`
+	Story     PublicStoryContent `json:"story"`
+	Theme     string             `json:"theme"`
+	CreatedAt time.Time          `json:"created_at"`
+	UpdatedAt time.Time          `json:"updated_at"`
+}
+
+// PublicStoryContent contains the public part of the story content
+type PublicStoryContent struct {
+	Title               string            `json:"title"`
+	NewsArticle         NewsArticle       `json:"news_article"`
+	StartingLocationIDs []string          `json:"starting_location_ids"`
+	Characters          []PublicCharacter `json:"characters"`
+	Locations           []PublicLocation  `json:"locations"`
+	CoverImageURL       string            `json:"cover_image_url,omitempty"`
+}
+
+// PublicCharacter is what a player can see of a character before talking to them
+type PublicCharacter struct {
+	ID                        string                     `json:"id"`
+	Name                      string                     `json:"name"`
+	Gender                    string                     `json:"gender"`
+	AppearanceDescription     string                     `json:"appearance_description"`
+	InGameCharacterVisualData *InGameCharacterVisualData `json:"in_game_character_visual_data,omitempty"`
+	ImageURL                  string                     `json:"image_url,omitempty"`
+}
+
+// PublicLocation is a location the player can visit, without container secrets
+type PublicLocation struct {
+	ID                     string            `json:"id"`
+	LocationName           string            `json:"location_name"`
+	VisualDescription      string            `json:"visual_description"`
+	CharacterIDsInLocation []string          `json:"character_ids_in_location"`
+	ImageURL               string            `json:"image_url,omitempty"`
+	Containers             []PublicContainer `json:"containers"`
+}
+
+// PublicContainer describes a container without its code, hint or contents
+type PublicContainer struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Type        string `json:"type"`
+	Description string `json:"description"`
+	IsLocked    bool   `json:"is_locked"`
+	Difficulty  string `json:"difficulty"`
+}
+
+// NewPublicStory projects a story down to what a player sees at the start of
+// the case. Only the starting locations are included.
+func NewPublicStory(story *Story) PublicStory {
+	content := PublicStoryContent{
+		Title:               story.Story.Title,
+		NewsArticle:         story.Story.NewsArticle,
+		StartingLocationIDs: story.Story.StartingLocationIDs,
+		Characters:          make([]PublicCharacter, 0, len(story.Story.Characters)),
+		Locations:           make([]PublicLocation, 0, len(story.Story.StartingLocationIDs)),
+		CoverImageURL:       story.Story.CoverImageURL,
+	}
+
+	for _, c := range story.Story.Characters {
+		content.Characters = append(content.Characters, PublicCharacter{
+			ID:                        c.ID,
+			Name:                      c.Name,
+			Gender:                    c.Gender,
+			AppearanceDescription:     c.AppearanceDescription,
+			InGameCharacterVisualData: c.InGameCharacterVisualData,
+			ImageURL:                  c.ImageURL,
+		})
+	}
+
+	for _, l := range story.Story.Locations {
+		if !slices.Contains(story.Story.StartingLocationIDs, l.ID) {
+			continue
+		}
+		content.Locations = append(content.Locations, NewPublicLocation(l))
+	}
+
+	return PublicStory{
+		ID:        story.ID,
+		Story:     content,
+		Theme:     story.Theme,
+		CreatedAt: story.CreatedAt,
+		UpdatedAt: story.UpdatedAt,
+	}
+}
+
+// NewPublicLocation projects a location down to what a player sees on arrival
+func NewPublicLocation(l Location) PublicLocation {
+	location := PublicLocation{
+		ID:                     l.ID,
+		LocationName:           l.LocationName,
+		VisualDescription:      l.VisualDescription,
+		CharacterIDsInLocation: l.CharacterIDsInLocation,
+		ImageURL:               l.ImageURL,
+		Containers:             make([]PublicContainer, 0, len(l.Containers)),
+	}
+	for _, c := range l.Containers {
+		location.Containers = append(location.Containers, PublicContainer{
+			ID:          c.ID,
+			Name:        c.Name,
+			Type:        c.Type,
+			Description: c.Description,
+			IsLocked:    c.IsLocked,
+			Difficulty:  c.Difficulty,
+		})
+	}
+	return location
+}
@@ -0,0 +1,63 @@
+package models
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestNewPublicStoryHidesSpoilers(t *testing.T) {
+	story := &Story{
+		RawStory: "RAW_SECRET",
+		Story: StoryContent{
+			Title:               "The Lighthouse",
+			FullStory:           "FULL_SECRET: the keeper did it",
+			StartingLocationIDs: []string{"loc_1"},
+			Characters: []Character{{
+				ID:                 "char_1",
+				Name:               "Agnes",
+				PersonalityProfile: "PERSONALITY_SECRET",
+				KnowledgeBase:      "KNOWLEDGE_SECRET",
+				HoldsEvidence:      []Evidence{{ID: "evid_1", Title: "EVIDENCE_SECRET"}},
+			}},
+			Locations: []Location{
+				{
+					ID:           "loc_1",
+					LocationName: "Docks",
+					Containers: []Container{{
+						ID:               "container_1",
+						Name:             "Tackle box",
+						IsLocked:         true,
+						UnlockCode:       "CODE_SECRET",
+						CodeHint:         CodeHint{Description: "HINT_SECRET"},
+						ContainsEvidence: []Evidence{{ID: "evid_2", Title: "CONTAINER_SECRET"}},
+					}},
+				},
+				{ID: "loc_2", LocationName: "HIDDEN_LOCATION"},
+			},
+		},
+	}
+
+	public := NewPublicStory(story)
+	body, err := json.Marshal(public)
+	if err != nil {
+		t.Fatalf("marshal failed: %v", err)
+	}
+
+	for _, secret := range []string{"RAW_SECRET", "FULL_SECRET", "PERSONALITY_SECRET", "KNOWLEDGE_SECRET",
+		"EVIDENCE_SECRET", "CODE_SECRET", "HINT_SECRET", "CONTAINER_SECRET", "HIDDEN_LOCATION"} {
+		if strings.Contains(string(body), secret) {
+			t.Errorf("public story leaks %s: %s", secret, body)
+		}
+	}
+
+	if len(public.Story.Locations) != 1 || public.Story.Locations[0].ID != "loc_1" {
+		t.Errorf("expected only the starting location, got %+v", public.Story.Locations)
+	}
+	if len(public.Story.Characters) != 1 || public.Story.Characters[0].Name != "Agnes" {
+		t.Errorf("expected the character's public info, got %+v", public.Story.Characters)
+	}
+	if c := public.Story.Locations[0].Containers; len(c) != 1 || !c[0].IsLocked {
+		t.Errorf("expected the locked container to be listed, got %+v", c)
+	}
+}
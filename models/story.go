@@ -25,6 +25,14 @@ type StoryContent struct {
 	Locations           []Location  `bson:"locations" json:"locations"`
 	FullStory           string      `bson:"full_story" json:"full_story"`
 	CoverImageURL       string      `bson:"cover_image_url,omitempty" json:"cover_image_url,omitempty"`
+	Solution            *Solution   `bson:"solution,omitempty" json:"solution,omitempty"`
+}
+
+// Solution is the machine-readable answer to the case, used to check accusations
+type Solution struct {
+	CulpritIDs     []string `bson:"culprit_ids" json:"culprit_ids"`           // Character IDs of everyone responsible
+	KeyEvidenceIDs []string `bson:"key_evidence_ids" json:"key_evidence_ids"` // Evidence that proves the case
+	MotiveTags     []string `bson:"motive_tags" json:"motive_tags"`           // Short labels such as "inheritance" or "blackmail"
 }
 
 // NewsArticle represents the news article within the story
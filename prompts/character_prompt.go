@@ -51,7 +51,7 @@ func ConstructCharacterSystemPrompt(character *models.Character, story *models.S
 	presentInLocations := "You can be only found in the following locations, never promise to meet outside of these locations:\n"
 	for _, locId := range story.Story.Locations {
 		if slices.Contains(locId.CharacterIDsInLocation, character.ID) {
-			presentInLocations += fmt.Sprintf("- [%s]: %s\n", locId, locId.LocationName)
+			presentInLocations += fmt.Sprintf("- [%s]: %s\n", locId.ID, locId.LocationName)
 		}
 	}
 
@@ -0,0 +1,50 @@
+package prompts
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ConstructHistorySummaryPrompt asks for a rolling summary of a character's
+// interrogation that replaces the transcript of its older turns. The previous
+// summary, if any, is folded into the new one.
+func ConstructHistorySummaryPrompt(characterName, previousSummary, transcript string, revealedEvidenceIDs, revealedLocationIDs []string) string {
+	if previousSummary == "" {
+		previousSummary = "(none - this is the start of the interrogation)"
+	}
+
+	return fmt.Sprintf(`You are maintaining the memory of %s, a character in a murder mystery who is being questioned by an investigator.
+
+Rewrite the previous summary and the transcript below into a single updated summary. %s will use it in place of the transcript, so anything left out is forgotten.
+
+The summary MUST preserve:
+- Every promise, deal, threat or favour either side made, and whether it was kept
+- Every piece of evidence and every location %s revealed or handed over, with its ID
+- Evidence the investigator presented and how %s reacted
+- Every lie %s told, and every contradiction the investigator pointed out or could point out
+- What the investigator knows, suspects or has accused anyone of
+- How %s feels about the investigator and why
+
+Write in the third person and the past tense, as compact bullet points. Keep names, IDs and specific details exactly as they appear. Stay under 400 words. Do not invent anything that is not in the previous summary or the transcript.
+
+Answer with the summary only.
+
+EVIDENCE REVEALED SO FAR: %s
+LOCATIONS REVEALED SO FAR: %s
+
+PREVIOUS SUMMARY:
+%s
+
+TRANSCRIPT OF THE TURNS TO FOLD IN:
+%s`,
+		characterName, characterName, characterName, characterName, characterName, characterName,
+		listOrNone(revealedEvidenceIDs), listOrNone(revealedLocationIDs),
+		previousSummary, transcript)
+}
+
+func listOrNone(ids []string) string {
+	if len(ids) == 0 {
+		return "none"
+	}
+	return strings.Join(ids, ", ")
+}
@@ -0,0 +1,70 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often full, and so forgettable, buckets are dropped
+const sweepInterval = time.Minute
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	budget  Budget
+}
+
+// MemoryStore keeps buckets in process memory. Each server instance counts
+// separately, so use MongoStore when running more than one.
+type MemoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: map[string]*bucket{}, now: time.Now}
+}
+
+// Take implements Store
+func (s *MemoryStore) Take(ctx context.Context, key string, budget Budget) (Decision, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if now.Sub(s.lastSweep) >= sweepInterval {
+		s.sweep(now)
+	}
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(budget.Burst), updated: now}
+		s.buckets[key] = b
+	}
+	b.budget = budget
+	b.tokens = refill(b.tokens, now.Sub(b.updated), budget)
+	b.updated = now
+
+	if b.tokens < 1 {
+		return Decision{RetryAfter: retryAfter(b.tokens, budget)}, nil
+	}
+	b.tokens--
+	return Decision{Allowed: true}, nil
+}
+
+// sweep drops buckets that have refilled completely, since a new bucket would be identical
+func (s *MemoryStore) sweep(now time.Time) {
+	for key, b := range s.buckets {
+		if refill(b.tokens, now.Sub(b.updated), b.budget) >= float64(b.budget.Burst) {
+			delete(s.buckets, key)
+		}
+	}
+	s.lastSweep = now
+}
+
+func refill(tokens float64, elapsed time.Duration, budget Budget) float64 {
+	return min(float64(budget.Burst), tokens+elapsed.Seconds()*budget.Rate)
+}
@@ -0,0 +1,21 @@
+package ratelimit
+
+import (
+	"agent/db"
+	"context"
+)
+
+// MongoStore keeps buckets in MongoDB so every server instance shares them
+type MongoStore struct{}
+
+// Take implements Store
+func (MongoStore) Take(ctx context.Context, key string, budget Budget) (Decision, error) {
+	allowed, tokens, err := db.TakeRateLimitToken(ctx, key, budget.Rate, budget.Burst)
+	if err != nil {
+		return Decision{}, err
+	}
+	if !allowed {
+		return Decision{RetryAfter: retryAfter(tokens, budget)}, nil
+	}
+	return Decision{Allowed: true}, nil
+}
@@ -0,0 +1,167 @@
+// Package ratelimit throttles expensive endpoints with token buckets, one per
+// client and route.
+package ratelimit
+
+import (
+	"agent/config"
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Route budgets. Routes without a budget are not limited.
+const (
+	RouteScore   = "score"   // Theory scoring, one or more LLM judgments per request
+	RouteMessage = "message" // Character replies plus tone and reveal detection
+	RouteFeed    = "feed"    // Story feed and details
+	RouteGuest   = "guest"   // Guest token issuing
+	RouteUnlock  = "unlock"  // Container code guesses
+)
+
+// defaultBudgets apply when RATE_LIMIT_<ROUTE> is not set
+var defaultBudgets = map[string]string{
+	RouteScore:   "5/m:3",
+	RouteMessage: "20/m:5",
+	RouteFeed:    "120/m:30",
+	RouteGuest:   "10/h:3",
+	RouteUnlock:  "10/m:5",
+}
+
+// Budget is a token bucket: up to Burst requests at once, refilled at Rate
+// requests per second
+type Budget struct {
+	Rate  float64
+	Burst int
+}
+
+// ParseBudget parses "N/period" or "N/period:burst", where period is s, m, h or
+// a Go duration, e.g. "5/m:3" or "100/30s". The burst defaults to N.
+func ParseBudget(s string) (Budget, error) {
+	spec, burstStr, hasBurst := strings.Cut(strings.TrimSpace(s), ":")
+	countStr, periodStr, ok := strings.Cut(spec, "/")
+	if !ok {
+		return Budget{}, fmt.Errorf("budget %q is not N/period", s)
+	}
+
+	count, err := strconv.Atoi(countStr)
+	if err != nil || count <= 0 {
+		return Budget{}, fmt.Errorf("budget %q has an invalid count", s)
+	}
+
+	var period time.Duration
+	switch periodStr {
+	case "s":
+		period = time.Second
+	case "m":
+		period = time.Minute
+	case "h":
+		period = time.Hour
+	default:
+		if period, err = time.ParseDuration(periodStr); err != nil || period <= 0 {
+			return Budget{}, fmt.Errorf("budget %q has an invalid period", s)
+		}
+	}
+
+	burst := count
+	if hasBurst {
+		if burst, err = strconv.Atoi(burstStr); err != nil || burst <= 0 {
+			return Budget{}, fmt.Errorf("budget %q has an invalid burst", s)
+		}
+	}
+
+	return Budget{Rate: float64(count) / period.Seconds(), Burst: burst}, nil
+}
+
+// Decision is the outcome of taking a token
+type Decision struct {
+	Allowed    bool
+	RetryAfter time.Duration // How long until a token is available, when not allowed
+}
+
+// Store keeps the buckets. Take removes one token from the bucket for key,
+// creating a full bucket if there is none.
+type Store interface {
+	Take(ctx context.Context, key string, budget Budget) (Decision, error)
+}
+
+// retryAfter returns how long a bucket holding tokens needs to refill one
+func retryAfter(tokens float64, budget Budget) time.Duration {
+	return time.Duration(math.Ceil((1 - tokens) / budget.Rate * float64(time.Second)))
+}
+
+// Limiter applies per-route budgets to clients
+type Limiter struct {
+	store   Store
+	budgets map[string]Budget
+}
+
+// NewLimiter creates a limiter with the given store and route budgets
+func NewLimiter(store Store, budgets map[string]Budget) *Limiter {
+	return &Limiter{store: store, budgets: budgets}
+}
+
+// Allow takes a token from the client's bucket for the route. Routes without a
+// budget are always allowed.
+func (l *Limiter) Allow(ctx context.Context, route, client string) (Decision, error) {
+	budget, ok := l.budgets[route]
+	if !ok {
+		return Decision{Allowed: true}, nil
+	}
+	return l.store.Take(ctx, route+"|"+client, budget)
+}
+
+var (
+	defaultLimiter *Limiter
+	defaultMu      sync.RWMutex
+)
+
+// Init builds the limiter from RATE_LIMIT_BACKEND and the RATE_LIMIT_<ROUTE>
+// budgets and installs it as the default
+func Init() error {
+	budgets := map[string]Budget{}
+	for route, def := range defaultBudgets {
+		spec := config.GetRateLimit(route, def)
+		if spec == "off" {
+			continue
+		}
+		budget, err := ParseBudget(spec)
+		if err != nil {
+			return fmt.Errorf("RATE_LIMIT_%s: %w", strings.ToUpper(route), err)
+		}
+		budgets[route] = budget
+	}
+
+	var store Store
+	switch backend := config.GetRateLimitBackend(); backend {
+	case "memory":
+		store = NewMemoryStore()
+	case "mongo":
+		store = MongoStore{}
+	default:
+		return fmt.Errorf("unknown rate limit backend %q", backend)
+	}
+
+	SetDefault(NewLimiter(store, budgets))
+	log.Printf("[RATE_LIMIT] Using %s backend with %d route budgets", config.GetRateLimitBackend(), len(budgets))
+	return nil
+}
+
+// SetDefault replaces the limiter returned by Default
+func SetDefault(l *Limiter) {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	defaultLimiter = l
+}
+
+// Default returns the limiter installed by Init or SetDefault, or nil if rate
+// limiting is not set up
+func Default() *Limiter {
+	defaultMu.RLock()
+	defer defaultMu.RUnlock()
+	return defaultLimiter
+}
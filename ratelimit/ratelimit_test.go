@@ -0,0 +1,83 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestParseBudget(t *testing.T) {
+	cases := map[string]Budget{
+		"5/m:3":    {Rate: 5.0 / 60, Burst: 3},
+		"10/s":     {Rate: 10, Burst: 10},
+		"100/30s":  {Rate: 100.0 / 30, Burst: 100},
+		" 2/h:1 ":  {Rate: 2.0 / 3600, Burst: 1},
+		"60/1m:10": {Rate: 1, Burst: 10},
+	}
+	for spec, want := range cases {
+		got, err := ParseBudget(spec)
+		if err != nil || got != want {
+			t.Errorf("ParseBudget(%q) = %+v, %v; want %+v", spec, got, err, want)
+		}
+	}
+
+	for _, spec := range []string{"", "5", "0/m", "5/x", "5/m:0", "-1/m", "5/-1s"} {
+		if _, err := ParseBudget(spec); err == nil {
+			t.Errorf("ParseBudget(%q) should fail", spec)
+		}
+	}
+}
+
+func TestMemoryStoreRefillsOverTime(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	store := NewMemoryStore()
+	store.now = func() time.Time { return now }
+	budget := Budget{Rate: 1.0 / 10, Burst: 2} // One token every 10 seconds
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if d, _ := store.Take(ctx, "score|player:p1", budget); !d.Allowed {
+			t.Fatalf("expected burst request %d to be allowed", i+1)
+		}
+	}
+	d, _ := store.Take(ctx, "score|player:p1", budget)
+	if d.Allowed || d.RetryAfter != 10*time.Second {
+		t.Fatalf("expected the third request to wait 10s, got %+v", d)
+	}
+
+	// Other clients have their own bucket
+	if d, _ := store.Take(ctx, "score|player:p2", budget); !d.Allowed {
+		t.Error("expected another player to be allowed")
+	}
+
+	now = now.Add(4 * time.Second)
+	if d, _ := store.Take(ctx, "score|player:p1", budget); d.Allowed || d.RetryAfter != 6*time.Second {
+		t.Errorf("expected to wait the remaining 6s, got %+v", d)
+	}
+	now = now.Add(6 * time.Second)
+	if d, _ := store.Take(ctx, "score|player:p1", budget); !d.Allowed {
+		t.Error("expected a refilled token to be allowed")
+	}
+
+	// Buckets that have refilled completely are forgotten
+	now = now.Add(time.Hour)
+	store.Take(ctx, "score|player:p3", budget)
+	if len(store.buckets) != 1 {
+		t.Errorf("expected idle buckets to be swept, %d left", len(store.buckets))
+	}
+}
+
+func TestLimiterOnlyLimitsRoutesWithBudgets(t *testing.T) {
+	limiter := NewLimiter(NewMemoryStore(), map[string]Budget{RouteScore: {Rate: 0.001, Burst: 1}})
+	ctx := context.Background()
+
+	for i := 0; i < 5; i++ {
+		if d, _ := limiter.Allow(ctx, "history", "ip:127.0.0.1"); !d.Allowed {
+			t.Fatal("expected a route without a budget to be unlimited")
+		}
+	}
+	limiter.Allow(ctx, RouteScore, "ip:127.0.0.1")
+	if d, _ := limiter.Allow(ctx, RouteScore, "ip:127.0.0.1"); d.Allowed {
+		t.Error("expected the score budget to be spent")
+	}
+}
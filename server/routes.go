@@ -0,0 +1,69 @@
+package server
+
+import (
+	"net/http"
+
+	"agent/handlers"
+	"agent/metrics"
+	"agent/middleware"
+	"agent/ratelimit"
+)
+
+// Routes returns the API routes. Each pattern names its method, so the mux
+// answers 405 for the others and handlers don't check r.Method themselves.
+// Authentication and rate limits are applied per route; the middleware shared
+// by every route is added by Handler.
+func Routes() *http.ServeMux {
+	mux := http.NewServeMux()
+
+	// Operations
+	mux.HandleFunc("GET /healthz", handlers.HealthzHandler)
+	mux.HandleFunc("GET /readyz", handlers.ReadyzHandler)
+	mux.HandleFunc("GET /version", handlers.VersionHandler)
+	mux.Handle("GET /metrics", metrics.Handler())
+
+	// Players
+	mux.HandleFunc("POST /auth/guest", middleware.RateLimit(ratelimit.RouteGuest, handlers.GuestTokenHandler))
+	mux.HandleFunc("POST /sessions", middleware.RequirePlayer(handlers.SessionCreateHandler))
+	mux.HandleFunc("POST /sessions/resume", middleware.RequirePlayer(handlers.SessionResumeHandler))
+	mux.HandleFunc("GET /sessions/{id}", middleware.RequirePlayer(handlers.SessionDetailRESTHandler))
+	mux.HandleFunc("GET /sessions/{id}/events", middleware.RequirePlayer(handlers.SessionEventsHandler))
+	mux.HandleFunc("GET /sessions/{id}/locations/{loc}", middleware.RequirePlayer(handlers.SessionLocationHandler))
+	mux.HandleFunc("POST /spawn", middleware.RequirePlayer(handlers.SpawnAgentHandler))
+	mux.HandleFunc("POST /message", middleware.RequirePlayer(middleware.RateLimit(ratelimit.RouteMessage, handlers.MessageHandler)))
+	mux.HandleFunc("POST /message/stream", middleware.RequirePlayer(middleware.RateLimit(ratelimit.RouteMessage, handlers.MessageStreamHandler)))
+	mux.HandleFunc("POST /containers/unlock", middleware.RequirePlayer(middleware.RateLimit(ratelimit.RouteUnlock, handlers.ContainerUnlockHandler)))
+	mux.HandleFunc("GET /agent/history", middleware.RequirePlayer(handlers.HistoryHandler))
+	mux.HandleFunc("POST /agent/history", middleware.RequirePlayer(handlers.HistoryHandler))
+	mux.HandleFunc("POST /score", middleware.RequirePlayer(middleware.RateLimit(ratelimit.RouteScore, handlers.ScoreTheoryHandler)))
+	mux.HandleFunc("GET /submissions", middleware.RequirePlayer(handlers.SubmissionHistoryHandler))
+	mux.HandleFunc("GET /leaderboard", middleware.RateLimit(ratelimit.RouteFeed, handlers.LeaderboardHandler))
+
+	// Stories
+	mux.HandleFunc("GET /feed", middleware.RateLimit(ratelimit.RouteFeed, handlers.FeedHandler))
+	mux.HandleFunc("GET /story", middleware.RateLimit(ratelimit.RouteFeed, handlers.StoryDetailHandler))
+	mux.HandleFunc("GET /stories/{id}", middleware.RateLimit(ratelimit.RouteFeed, handlers.StoryDetailRESTHandler))
+	mux.HandleFunc("GET /v2/feed", middleware.RateLimit(ratelimit.RouteFeed, handlers.FeedHandlerV2))
+	mux.HandleFunc("GET /v2/story", middleware.RateLimit(ratelimit.RouteFeed, handlers.StoryDetailHandlerV2))
+
+	// Authors
+	mux.HandleFunc("GET /author/stories/{id}", middleware.RequireAuthorKey(handlers.AuthorStoryHandler))
+	mux.HandleFunc("GET /author/validate", middleware.RequireAuthorKey(handlers.AuthorValidateHandler))
+	mux.HandleFunc("POST /author/validate", middleware.RequireAuthorKey(handlers.AuthorValidateHandler))
+
+	return mux
+}
+
+// Handler wraps the routes in the middleware every request goes through:
+// request ID first so the log line and any panic carry it, then logging,
+// metrics, panic recovery and CORS, which also answers preflight requests for
+// every route
+func Handler() http.Handler {
+	return middleware.Chain(Routes().ServeHTTP,
+		middleware.RequestID,
+		middleware.LogRequests,
+		middleware.RecordMetrics,
+		middleware.Recover,
+		middleware.EnableCORS,
+	)
+}
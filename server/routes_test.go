@@ -0,0 +1,47 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandlerRouting(t *testing.T) {
+	handler := Handler()
+
+	tests := []struct {
+		method, path string
+		want         int
+	}{
+		{"POST", "/feed", http.StatusMethodNotAllowed},
+		{"GET", "/message", http.StatusMethodNotAllowed},
+		{"OPTIONS", "/message", http.StatusOK},               // CORS preflight answered for every route
+		{"GET", "/stories/not-an-id", http.StatusBadRequest}, // {id} reaches the handler
+		{"GET", "/stories/a/b", http.StatusNotFound},
+		{"GET", "/nowhere", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest(tt.method, tt.path, nil))
+		if rr.Code != tt.want {
+			t.Errorf("%s %s = %d, want %d", tt.method, tt.path, rr.Code, tt.want)
+		}
+	}
+}
+
+func TestMetricsLabelRequestsByRoutePattern(t *testing.T) {
+	handler := Handler()
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/stories/not-an-id", nil))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/metrics", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("GET /metrics = %d, want %d", rr.Code, http.StatusOK)
+	}
+
+	want := `case_api_http_requests_total{method="GET",route="GET /stories/{id}",status="400"}`
+	if !strings.Contains(rr.Body.String(), want) {
+		t.Errorf("metrics output does not contain %s", want)
+	}
+}
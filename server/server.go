@@ -0,0 +1,102 @@
+// Package server runs the HTTP API: the route table, the middleware shared by
+// every route, and a graceful shutdown that lets in-flight requests and
+// background LLM calls finish before the caller flushes state and closes the database.
+package server
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"agent/config"
+	"agent/handlers"
+)
+
+// Config holds the listen address and timeouts of the HTTP server
+type Config struct {
+	Addr            string
+	ReadTimeout     time.Duration
+	WriteTimeout    time.Duration
+	IdleTimeout     time.Duration
+	ShutdownTimeout time.Duration // How long Run waits for in-flight work after ctx is done
+}
+
+// ConfigFromEnv reads the server configuration from the environment
+func ConfigFromEnv() Config {
+	return Config{
+		Addr:            config.GetServerAddr(),
+		ReadTimeout:     config.GetServerReadTimeout(),
+		WriteTimeout:    config.GetServerWriteTimeout(),
+		IdleTimeout:     config.GetServerIdleTimeout(),
+		ShutdownTimeout: config.GetShutdownTimeout(),
+	}
+}
+
+// ErrShutdownIncomplete is returned by Run when in-flight requests or background
+// work were still running at the shutdown timeout. They may still be writing to
+// the database, so the caller must not flush agents or close the database.
+var ErrShutdownIncomplete = errors.New("in-flight work did not finish before the shutdown timeout")
+
+// Server is the HTTP API server
+type Server struct {
+	cfg  Config
+	http *http.Server
+}
+
+// New creates a server for handler with the given configuration
+func New(cfg Config, handler http.Handler) *Server {
+	return &Server{
+		cfg: cfg,
+		http: &http.Server{
+			Addr:         cfg.Addr,
+			Handler:      handler,
+			ReadTimeout:  cfg.ReadTimeout,
+			WriteTimeout: cfg.WriteTimeout,
+			IdleTimeout:  cfg.IdleTimeout,
+		},
+	}
+}
+
+// Run serves requests until ctx is done, then stops accepting connections and
+// waits up to the shutdown timeout for in-flight requests and background work
+// such as history compaction to finish. WebSocket connections have been
+// hijacked from the server and are dropped when the process exits.
+// Run returns nil after a clean shutdown and ErrShutdownIncomplete if the
+// timeout ran out first.
+func (s *Server) Run(ctx context.Context) error {
+	errCh := make(chan error, 1)
+	go func() {
+		log.Printf("[SERVER] Listening on %s", s.cfg.Addr)
+		errCh <- s.http.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+
+	log.Printf("[SERVER] Shutting down, waiting up to %s for in-flight requests", s.cfg.ShutdownTimeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), s.cfg.ShutdownTimeout)
+	defer cancel()
+
+	complete := true
+	if err := s.http.Shutdown(shutdownCtx); err != nil {
+		log.Printf("[SERVER_ERROR] In-flight requests did not finish: %v", err)
+		complete = false
+	}
+	if err := handlers.WaitBackground(shutdownCtx); err != nil {
+		log.Printf("[SERVER_ERROR] Background work did not finish: %v", err)
+		complete = false
+	}
+
+	if err := <-errCh; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	if !complete {
+		return ErrShutdownIncomplete
+	}
+	return nil
+}
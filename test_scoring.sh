@@ -1,35 +1,34 @@
 #!/bin/bash
 
-# Test script for the enhanced scoring endpoint with evidence tracking
+# Test script for the session-based scoring endpoint
 
-echo "Testing Enhanced Scoring Endpoint"
-echo "================================="
+echo "Testing Session Scoring Endpoint"
+echo "================================"
 
-# Test 1: Without discovered evidence (backward compatibility)
-echo -e "\n1. Testing without evidence (backward compatibility):"
-curl -X POST http://localhost:8081/score \
+# Test 1: Create a session to score against
+echo -e "\n1. Creating a session:"
+SESSION_ID=$(curl -s -X POST http://localhost:8081/sessions \
   -H "Content-Type: application/json" \
   -d '{
-    "story_id": "YOUR_STORY_ID_HERE",
-    "theory": "I believe the butler did it because of the fingerprints on the knife."
-  }' | jq .
+    "player_id": "test-player",
+    "story_id": "YOUR_STORY_ID_HERE"
+  }' | jq -r .id)
+echo "Session: $SESSION_ID"
 
-# Test 2: With discovered evidence
-echo -e "\n2. Testing with discovered evidence:"
+# Test 2: Score using the evidence discovered in the session
+echo -e "\n2. Testing with the session's discovered evidence:"
 curl -X POST http://localhost:8081/score \
   -H "Content-Type: application/json" \
   -d '{
-    "story_id": "YOUR_STORY_ID_HERE",
-    "theory": "I believe the butler did it because of the fingerprints on the knife.",
-    "discovered_evidence": ["evidence_001", "evidence_002"]
+    "session_id": "'"$SESSION_ID"'",
+    "theory": "I believe the butler did it because of the fingerprints on the knife."
   }' | jq .
 
-# Test 3: With empty evidence array
-echo -e "\n3. Testing with empty evidence array:"
+# Test 3: Missing session
+echo -e "\n3. Testing without a session (expect 400):"
 curl -X POST http://localhost:8081/score \
   -H "Content-Type: application/json" \
   -d '{
     "story_id": "YOUR_STORY_ID_HERE",
-    "theory": "I believe the butler did it because of the fingerprints on the knife.",
-    "discovered_evidence": []
-  }' | jq .
\ No newline at end of file
+    "theory": "I believe the butler did it because of the fingerprints on the knife."
+  }' | jq .
@@ -0,0 +1,303 @@
+// Package validator checks generated stories for broken references and for
+// evidence, characters and locations a player can never reach.
+package validator
+
+import (
+	"agent/models"
+	"fmt"
+	"slices"
+)
+
+// Issue severities
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+// Issue codes
+const (
+	CodeDuplicateID          = "duplicate_id"
+	CodeUnknownReference     = "unknown_reference"
+	CodeNoStartingLocation   = "no_starting_location"
+	CodeMissingUnlockCode    = "missing_unlock_code"
+	CodeUnreachableThreshold = "unreachable_threshold"
+	CodeUnreachableEvidence  = "unreachable_evidence"
+	CodeUnreachableCharacter = "unreachable_character"
+	CodeUnreachableLocation  = "unreachable_location"
+)
+
+// maxStanding is the highest reputation or intimidation a character can reach,
+// matching the clamp applied by the agent standing rules
+const maxStanding = 100
+
+// Issue is a single problem found in a story
+type Issue struct {
+	Severity string `json:"severity"`
+	Code     string `json:"code"`
+	ID       string `json:"id,omitempty"` // The character, location, container or evidence concerned
+	Message  string `json:"message"`
+}
+
+// Report is the result of validating a story. A story is valid when it has no errors;
+// warnings point at content players will never see but don't block the case.
+type Report struct {
+	Valid               bool     `json:"valid"`
+	Issues              []Issue  `json:"issues"`
+	ReachableLocations  []string `json:"reachable_locations"`
+	ReachableCharacters []string `json:"reachable_characters"`
+	ReachableEvidence   []string `json:"reachable_evidence"`
+}
+
+// Errors returns only the issues with error severity
+func (r Report) Errors() []Issue {
+	var errs []Issue
+	for _, issue := range r.Issues {
+		if issue.Severity == SeverityError {
+			errs = append(errs, issue)
+		}
+	}
+	return errs
+}
+
+// index maps IDs to the story elements they name
+type index struct {
+	characters map[string]*models.Character
+	locations  map[string]*models.Location
+	evidence   map[string]*models.Evidence
+}
+
+// Validate checks referential integrity and reachability of a story
+func Validate(story *models.Story) Report {
+	report := Report{Issues: []Issue{}}
+	content := &story.Story
+
+	idx := buildIndex(content, &report)
+	checkReferences(content, idx, &report)
+	checkReachability(content, idx, &report)
+
+	report.Valid = len(report.Errors()) == 0
+	return report
+}
+
+func (r *Report) add(severity, code, id, format string, args ...any) {
+	r.Issues = append(r.Issues, Issue{
+		Severity: severity,
+		Code:     code,
+		ID:       id,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// buildIndex indexes every element by ID, reporting duplicates
+func buildIndex(content *models.StoryContent, report *Report) index {
+	idx := index{
+		characters: map[string]*models.Character{},
+		locations:  map[string]*models.Location{},
+		evidence:   map[string]*models.Evidence{},
+	}
+	containers := map[string]bool{}
+
+	addEvidence := func(e *models.Evidence) {
+		if _, dup := idx.evidence[e.ID]; dup {
+			report.add(SeverityError, CodeDuplicateID, e.ID, "evidence %s is defined more than once", e.ID)
+			return
+		}
+		idx.evidence[e.ID] = e
+	}
+
+	for i := range content.Characters {
+		c := &content.Characters[i]
+		if _, dup := idx.characters[c.ID]; dup {
+			report.add(SeverityError, CodeDuplicateID, c.ID, "character %s is defined more than once", c.ID)
+		} else {
+			idx.characters[c.ID] = c
+		}
+		for j := range c.HoldsEvidence {
+			addEvidence(&c.HoldsEvidence[j])
+		}
+	}
+
+	for i := range content.Locations {
+		l := &content.Locations[i]
+		if _, dup := idx.locations[l.ID]; dup {
+			report.add(SeverityError, CodeDuplicateID, l.ID, "location %s is defined more than once", l.ID)
+		} else {
+			idx.locations[l.ID] = l
+		}
+		for j := range l.Containers {
+			c := &l.Containers[j]
+			if containers[c.ID] {
+				report.add(SeverityError, CodeDuplicateID, c.ID, "container %s is defined more than once", c.ID)
+			}
+			containers[c.ID] = true
+			for k := range c.ContainsEvidence {
+				addEvidence(&c.ContainsEvidence[k])
+			}
+		}
+	}
+
+	return idx
+}
+
+// checkReferences reports IDs that point at elements that don't exist
+func checkReferences(content *models.StoryContent, idx index, report *Report) {
+	if len(content.StartingLocationIDs) == 0 {
+		report.add(SeverityError, CodeNoStartingLocation, "", "story has no starting locations")
+	}
+	for _, id := range content.StartingLocationIDs {
+		if idx.locations[id] == nil {
+			report.add(SeverityError, CodeUnknownReference, id, "starting location %s does not exist", id)
+		}
+	}
+
+	for _, c := range content.Characters {
+		for _, id := range c.KnowsLocationIDs {
+			if idx.locations[id] == nil {
+				report.add(SeverityError, CodeUnknownReference, id, "character %s knows location %s, which does not exist", c.ID, id)
+			}
+		}
+		for _, e := range c.HoldsEvidence {
+			if e.MinReputation > maxStanding || e.MinIntimidation > maxStanding {
+				report.add(SeverityError, CodeUnreachableThreshold, e.ID,
+					"evidence %s held by %s needs standing above %d, which can never be reached", e.ID, c.ID, maxStanding)
+			}
+		}
+	}
+
+	for _, l := range content.Locations {
+		for _, id := range l.CharacterIDsInLocation {
+			if idx.characters[id] == nil {
+				report.add(SeverityError, CodeUnknownReference, id, "location %s lists character %s, which does not exist", l.ID, id)
+			}
+		}
+		for _, c := range l.Containers {
+			if c.IsLocked && c.UnlockCode == "" {
+				report.add(SeverityError, CodeMissingUnlockCode, c.ID, "container %s in %s is locked but has no unlock code", c.ID, l.ID)
+			}
+		}
+	}
+
+	if s := content.Solution; s != nil {
+		for _, id := range s.CulpritIDs {
+			if idx.characters[id] == nil {
+				report.add(SeverityError, CodeUnknownReference, id, "solution culprit %s does not exist", id)
+			}
+		}
+		for _, id := range s.KeyEvidenceIDs {
+			if idx.evidence[id] == nil {
+				report.add(SeverityError, CodeUnknownReference, id, "solution key evidence %s does not exist", id)
+			}
+		}
+	}
+}
+
+// checkReachability walks the story the way a player can: from the starting
+// locations to the characters found there, from characters to the locations they
+// reveal and the evidence they hold, and from locations to the evidence inside
+// containers that can be opened. Anything not reached is reported.
+func checkReachability(content *models.StoryContent, idx index, report *Report) {
+	locations := map[string]bool{}
+	characters := map[string]bool{}
+	evidence := map[string]bool{}
+
+	var queue []string
+	visitLocation := func(id string) {
+		if idx.locations[id] != nil && !locations[id] {
+			locations[id] = true
+			queue = append(queue, id)
+		}
+	}
+	for _, id := range content.StartingLocationIDs {
+		visitLocation(id)
+	}
+
+	for len(queue) > 0 {
+		location := idx.locations[queue[0]]
+		queue = queue[1:]
+
+		for _, c := range location.Containers {
+			if c.IsLocked && c.UnlockCode == "" {
+				continue
+			}
+			for _, e := range c.ContainsEvidence {
+				evidence[e.ID] = true
+			}
+		}
+
+		for _, id := range location.CharacterIDsInLocation {
+			character := idx.characters[id]
+			if character == nil || characters[id] {
+				continue
+			}
+			characters[id] = true
+			for _, e := range character.HoldsEvidence {
+				if e.MinReputation <= maxStanding && e.MinIntimidation <= maxStanding {
+					evidence[e.ID] = true
+				}
+			}
+			for _, locID := range character.KnowsLocationIDs {
+				visitLocation(locID)
+			}
+		}
+	}
+
+	var solution models.Solution
+	if content.Solution != nil {
+		solution = *content.Solution
+	}
+
+	checkEvidence := func(e models.Evidence) {
+		if evidence[e.ID] {
+			return
+		}
+		switch {
+		case e.IsCritical:
+			report.add(SeverityError, CodeUnreachableEvidence, e.ID, "critical evidence %s can never be found", e.ID)
+		case slices.Contains(solution.KeyEvidenceIDs, e.ID):
+			report.add(SeverityError, CodeUnreachableEvidence, e.ID, "key evidence %s can never be found", e.ID)
+		default:
+			report.add(SeverityWarning, CodeUnreachableEvidence, e.ID, "evidence %s can never be found", e.ID)
+		}
+	}
+	for _, c := range content.Characters {
+		for _, e := range c.HoldsEvidence {
+			checkEvidence(e)
+		}
+	}
+	for _, l := range content.Locations {
+		for _, c := range l.Containers {
+			for _, e := range c.ContainsEvidence {
+				checkEvidence(e)
+			}
+		}
+	}
+
+	for _, c := range content.Characters {
+		if characters[c.ID] {
+			continue
+		}
+		if slices.Contains(solution.CulpritIDs, c.ID) {
+			report.add(SeverityError, CodeUnreachableCharacter, c.ID, "culprit %s is not in any reachable location", c.ID)
+		} else {
+			report.add(SeverityWarning, CodeUnreachableCharacter, c.ID, "character %s is not in any reachable location", c.ID)
+		}
+	}
+	for _, l := range content.Locations {
+		if !locations[l.ID] {
+			report.add(SeverityWarning, CodeUnreachableLocation, l.ID, "location %s is neither a starting location nor known to a reachable character", l.ID)
+		}
+	}
+
+	report.ReachableLocations = sortedKeys(locations)
+	report.ReachableCharacters = sortedKeys(characters)
+	report.ReachableEvidence = sortedKeys(evidence)
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
@@ -0,0 +1,91 @@
+package validator
+
+import (
+	"agent/models"
+	"testing"
+)
+
+func newValidStory() *models.Story {
+	return &models.Story{Story: models.StoryContent{
+		StartingLocationIDs: []string{"loc_1"},
+		Characters: []models.Character{
+			{ID: "char_1", KnowsLocationIDs: []string{"loc_2"}, HoldsEvidence: []models.Evidence{{ID: "evid_1", IsCritical: true}}},
+			{ID: "char_2", HoldsEvidence: []models.Evidence{{ID: "evid_2"}}},
+		},
+		Locations: []models.Location{
+			{ID: "loc_1", CharacterIDsInLocation: []string{"char_1"}},
+			{ID: "loc_2", CharacterIDsInLocation: []string{"char_2"}, Containers: []models.Container{
+				{ID: "safe_1", IsLocked: true, UnlockCode: "1987", ContainsEvidence: []models.Evidence{{ID: "evid_3", IsCritical: true}}},
+			}},
+		},
+		Solution: &models.Solution{CulpritIDs: []string{"char_2"}, KeyEvidenceIDs: []string{"evid_1", "evid_3"}},
+	}}
+}
+
+// issueCodes returns "code:id" for every issue in the report
+func issueCodes(r Report) map[string]string {
+	codes := map[string]string{}
+	for _, issue := range r.Issues {
+		codes[issue.Code+":"+issue.ID] = issue.Severity
+	}
+	return codes
+}
+
+func TestValidateAcceptsReachableStory(t *testing.T) {
+	report := Validate(newValidStory())
+	if !report.Valid || len(report.Issues) != 0 {
+		t.Fatalf("expected a valid story, got %+v", report.Issues)
+	}
+	if len(report.ReachableEvidence) != 3 || len(report.ReachableCharacters) != 2 || len(report.ReachableLocations) != 2 {
+		t.Errorf("unexpected reachability %+v", report)
+	}
+}
+
+func TestValidateReportsBrokenReferences(t *testing.T) {
+	story := newValidStory()
+	story.Story.StartingLocationIDs = append(story.Story.StartingLocationIDs, "loc_404")
+	story.Story.Characters[0].KnowsLocationIDs = append(story.Story.Characters[0].KnowsLocationIDs, "loc_405")
+	story.Story.Locations[0].CharacterIDsInLocation = append(story.Story.Locations[0].CharacterIDsInLocation, "char_404")
+	story.Story.Solution.CulpritIDs = []string{"char_405"}
+	story.Story.Characters[1].HoldsEvidence = append(story.Story.Characters[1].HoldsEvidence, models.Evidence{ID: "evid_1"})
+
+	report := Validate(story)
+	if report.Valid {
+		t.Fatal("expected the story to be invalid")
+	}
+	codes := issueCodes(report)
+	for _, want := range []string{
+		"unknown_reference:loc_404",
+		"unknown_reference:loc_405",
+		"unknown_reference:char_404",
+		"unknown_reference:char_405",
+		"duplicate_id:evid_1",
+	} {
+		if codes[want] != SeverityError {
+			t.Errorf("expected error %s, got %v", want, codes)
+		}
+	}
+}
+
+func TestValidateReportsUnreachableContent(t *testing.T) {
+	story := newValidStory()
+	// Nobody reveals loc_2 any more, and the safe's code is lost
+	story.Story.Characters[0].KnowsLocationIDs = nil
+	story.Story.Locations[1].Containers[0].UnlockCode = ""
+	story.Story.Characters[0].HoldsEvidence[0].MinReputation = 120
+
+	codes := issueCodes(Validate(story))
+	for want, severity := range map[string]string{
+		"unreachable_location:loc_2":   SeverityWarning,
+		"unreachable_character:char_2": SeverityError, // The culprit
+		"unreachable_evidence:evid_1":  SeverityError, // Threshold above the standing cap
+		"unreachable_threshold:evid_1": SeverityError,
+		"unreachable_evidence:evid_2":  SeverityWarning,
+		"unreachable_evidence:evid_3":  SeverityError,
+		"missing_unlock_code:safe_1":   SeverityError,
+	} {
+		if codes[want] != severity {
+			t.Errorf("expected %s %s, got %q", severity, want, codes[want])
+		}
+	}
+}
@@ -0,0 +1,53 @@
+// Package version reports which build of the server is running
+package version
+
+import (
+	"runtime"
+	"runtime/debug"
+)
+
+// Version and Commit are set at build time, e.g.
+//
+//	go build -ldflags "-X agent/version.Version=v1.4.0 -X agent/version.Commit=$(git rev-parse HEAD)"
+//
+// Commit falls back to the VCS revision Go stamps into binaries built from a checkout.
+var (
+	Version = "dev"
+	Commit  = ""
+)
+
+// Info describes the running build
+type Info struct {
+	Version    string `json:"version"`
+	Commit     string `json:"commit,omitempty"`
+	CommitTime string `json:"commit_time,omitempty"`
+	Modified   bool   `json:"modified,omitempty"` // Built from a checkout with uncommitted changes
+	GoVersion  string `json:"go_version"`
+}
+
+// Get returns the build metadata of the running binary
+func Get() Info {
+	info := Info{
+		Version:   Version,
+		Commit:    Commit,
+		GoVersion: runtime.Version(),
+	}
+
+	build, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+	for _, s := range build.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			if info.Commit == "" {
+				info.Commit = s.Value
+			}
+		case "vcs.time":
+			info.CommitTime = s.Value
+		case "vcs.modified":
+			info.Modified = s.Value == "true"
+		}
+	}
+	return info
+}